@@ -6,3 +6,11 @@ CREATE TABLE IF NOT EXISTS `ghost_lists` (
   `ghost_value` varchar(16)  NOT NULL,
   PRIMARY KEY (`list_id`, `ghost_key`)
 ) ENGINE=InnoDB  DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
+
+-- Table backing the cache with the store flag
+
+CREATE TABLE IF NOT EXISTS `cache_entries` (
+  `entry_key` varchar(255) NOT NULL,
+  `entry_value` mediumblob NOT NULL,
+  PRIMARY KEY (`entry_key`)
+) ENGINE=InnoDB  DEFAULT CHARSET=utf8 COLLATE=utf8_unicode_ci;
@@ -0,0 +1,263 @@
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+const (
+	// sketchDepth is the number of rows of the count-min sketch
+	sketchDepth = 4
+	// maxFrequency is the largest count of the sketch, like the 4 bit counters of TinyLFU
+	maxFrequency = 15
+	// sampleFactor times the capacity is the number of accesses between two agings
+	sampleFactor = 10
+)
+
+// ErrRejected is returned by TryPut and TryPutIfAbsent when the admission policy keeps a new key out of the cache
+var ErrRejected = errors.New("arc: rejected by the admission policy")
+
+// tinyLFU estimates how often keys were accessed recently, to admit a new key only when it
+// is more popular than the one it would evict. A doorkeeper bloom filter keeps the keys seen
+// once out of the count-min sketch, and every sample accesses the counts are halved.
+type tinyLFU struct {
+	mask     uint64
+	rows     [sketchDepth][]uint8
+	door     []uint64
+	doorMask uint64
+	accesses int
+	sample   int
+}
+
+// SetAdmission function to only admit a new key in T1 when it was accessed more often than
+// the entry Case IV would evict for it, so that keys read once do not push out useful ones.
+// It applies to Put, PutWithTTL, TryPut, TryPutIfAbsent and PutMany.
+func SetAdmission(on bool) func(*ARC) {
+	return func(arc *ARC) {
+		arc.admission = nil
+		if on {
+			arc.admission = newTinyLFU(arc.c)
+		}
+	}
+}
+
+func newTinyLFU(c int) *tinyLFU {
+	width := nextPowerOfTwo(c)
+	t := &tinyLFU{
+		mask:     width - 1,
+		door:     make([]uint64, width/4),
+		doorMask: width*16 - 1,
+		sample:   sampleFactor * c,
+	}
+	if t.sample < sampleFactor*16 {
+		t.sample = sampleFactor * 16
+	}
+	for i := range t.rows {
+		t.rows[i] = make([]uint8, width)
+	}
+	return t
+}
+
+func nextPowerOfTwo(n int) uint64 {
+	p := uint64(16)
+	for p < uint64(n) {
+		p <<= 1
+	}
+	return p
+}
+
+// increment records an access to key, the first one only in the doorkeeper
+func (t *tinyLFU) increment(key interface{}) {
+	h := hashKey(key)
+	if t.doorkeeper(h, true) {
+		for i := range t.rows {
+			if c := &t.rows[i][t.index(h, i)]; *c < maxFrequency {
+				*c++
+			}
+		}
+	}
+	t.accesses++
+	if t.accesses >= t.sample {
+		t.age()
+	}
+}
+
+// estimate returns how many times key was accessed recently
+func (t *tinyLFU) estimate(key interface{}) int {
+	h := hashKey(key)
+	n := maxFrequency
+	for i := range t.rows {
+		if c := int(t.rows[i][t.index(h, i)]); c < n {
+			n = c
+		}
+	}
+	if t.doorkeeper(h, false) {
+		n++
+	}
+	return n
+}
+
+// admit reports whether candidate is worth evicting victim for
+func (t *tinyLFU) admit(candidate, victim interface{}) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+// age halves the counts and empties the doorkeeper, so that old popularity fades
+func (t *tinyLFU) age() {
+	for i := range t.rows {
+		for j := range t.rows[i] {
+			t.rows[i][j] >>= 1
+		}
+	}
+	for i := range t.door {
+		t.door[i] = 0
+	}
+	t.accesses /= 2
+}
+
+// index returns the counter of a row for a hash, by double hashing
+func (t *tinyLFU) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|h<<32|1)) & t.mask
+}
+
+// doorkeeper reports whether the hash was seen since the last aging, adding it when add is set
+func (t *tinyLFU) doorkeeper(h uint64, add bool) bool {
+	seen := true
+	for i := uint64(0); i < 2; i++ {
+		bit := (h >> (i * 32)) * 0x9e3779b97f4a7c15 & t.doorMask
+		if t.door[bit/64]&(1<<(bit%64)) == 0 {
+			seen = false
+			if add {
+				t.door[bit/64] |= 1 << (bit % 64)
+			}
+		}
+	}
+	return seen
+}
+
+// TryPut inserts a key-value pair like PutWithTTL, returning ErrRejected when the admission
+// policy keeps the key out of the cache, or the error of the store failing to save value. A
+// rejected value is still saved to the store set with SetStore.
+func (a *ARC) TryPut(key, value interface{}, ttl time.Duration) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.trace(TracePut, key)
+	if !a.admits(key) {
+		a.reject(key)
+		if err := a.storeValue(key, value); err != nil {
+			return false, err
+		}
+		return false, ErrRejected
+	}
+	return a.write(key, value, expiry(ttl))
+}
+
+// TryPutIfAbsent is PutIfAbsent going through the admission policy like TryPut, with a ttl set
+// atomically with the value. It reports whether the value was stored, false with a nil error
+// when the key already holds a value, ErrRejected when the policy keeps the key out of the
+// cache, or the error of the store failing to save value.
+func (a *ARC) TryPutIfAbsent(key, value interface{}, ttl time.Duration) (bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.live(key); ok {
+		return false, nil
+	}
+	a.trace(TracePut, key)
+	if !a.admits(key) {
+		a.reject(key)
+		if err := a.storeValue(key, value); err != nil {
+			return false, err
+		}
+		return false, ErrRejected
+	}
+	if _, err := a.write(key, value, expiry(ttl)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// admits records a Put of key and reports whether it may enter the cache. Only new keys are
+// filtered, since keys in T1, T2, B1 or B2 do not go through Case IV.
+func (a *ARC) admits(key interface{}) bool {
+	if a.admission == nil {
+		return true
+	}
+	a.admission.increment(key)
+	if _, ok := a.cache[key]; ok || a.ghostList(key) != nil {
+		return true
+	}
+	victim := a.victim()
+	if victim == nil || a.admission.admit(key, victim.key) {
+		return true
+	}
+	a.rejected++
+	if a.debugging() {
+		a.logger.Debug("New item rejected by the admission policy", "item_key", fmt.Sprintf("%s", key), "victim_key", fmt.Sprintf("%s", victim.key))
+	}
+	return false
+}
+
+// reject lets the shadows see a Put rejected by the admission policy, they have none
+func (a *ARC) reject(key interface{}) {
+	for _, s := range a.shadows {
+		s.Put(key)
+	}
+}
+
+// victim returns the entry of T1 or T2 which Case IV would evict to insert a new key, or nil
+func (a *ARC) victim() *entry {
+	t1, b1 := a.t1.Len(), a.b1Len()
+	switch {
+	case t1+b1 == a.c && t1 >= a.c:
+		if e := lruUnpinned(a.t1); e != nil {
+			return e.Value.(*entry)
+		}
+	case t1+b1 == a.c, t1+b1 < a.c && t1+a.t2.Len()+b1+a.b2Len() >= a.c:
+		// the entry REPLACE would move to B1 or B2
+		if a.full() {
+			return a.replaceVictim(false)
+		}
+	}
+	return nil
+}
+
+// hashKey hashes the comparable keys of the cache, integers and strings without formatting them
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	}
+	return hashString(fmt.Sprintf("%T %v", key, key))
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix spreads the bits of an integer, as the finalizer of splitmix64
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -4,6 +4,8 @@ package arc
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/deepak11627/arc/utils"
 )
@@ -19,17 +21,74 @@ import (
 // - Hit in B1 should increase size of T1, drop entry from T2 to B2
 // - Hit in B2 should increase size of T2, drop entry from T1 to B1
 type ARC struct {
-	p      int
-	c      int
-	t1     ListService
-	t2     ListService
-	b1     ListService
-	b2     ListService
-	mutex  sync.RWMutex
-	len    int
-	cache  map[interface{}]*entry
-	logger Logger
-	db     DBService
+	p       int
+	c       int
+	t1      ListService
+	t2      ListService
+	b1      ListService
+	b2      ListService
+	mutex   sync.RWMutex
+	len     int
+	cache   map[interface{}]*entry
+	logger  Logger
+	debug   DebugLogger
+	db      DBService
+	hits    uint64
+	misses  uint64
+	shadows []*shadow
+
+	observers []Observer
+	tracer    TraceService
+
+	auditSize int
+	audit     []KeyInfo
+	auditNext int
+
+	codec Codec
+	wal   *wal
+	reads *reads
+
+	admission *tinyLFU
+	rejected  uint64
+
+	// g1 and g2 keep B1 and B2 as fingerprints with compact ghost lists
+	g1 *fingerprints
+	g2 *fingerprints
+
+	pinned    int
+	pinnedCap int
+
+	// keys is the sorted index of the string keys set with SetKeyIndex, tags the keys of every tag
+	keys             *keyIndex
+	tags             map[string]map[interface{}]struct{}
+	invalidateGhosts bool
+
+	loader       Loader
+	refreshAhead time.Duration
+	staleWindow  time.Duration
+	staleHits    uint64
+	// loading holds the loads in flight, loadMutex guards it and the load counters
+	loadMutex  sync.Mutex
+	loading    map[interface{}]*load
+	loads      uint64
+	loadErrors uint64
+
+	store         *store
+	flushInterval time.Duration
+	storeErrors   func(key interface{}, err error)
+
+	// version is the version of the last value stored
+	version uint64
+
+	ghostHitsB1 uint64
+	ghostHitsB2 uint64
+	evictions   map[EvictionReason]uint64
+	dbWrites    uint64
+	dbErrors    uint64
+	dbWriteTime time.Duration
+	// dbBatch holds the database writes of GetMany and PutMany until the end of the batch
+	dbBatch  []DBOp
+	batching bool
 }
 
 // Option type setting params dynamically
@@ -39,6 +98,7 @@ type Option func(*ARC)
 func SetLogger(l Logger) func(*ARC) {
 	return func(arc *ARC) {
 		arc.logger = l
+		arc.debug, _ = l.(DebugLogger)
 	}
 }
 
@@ -52,18 +112,28 @@ func SetDatabaseListService(db DBService) func(*ARC) {
 // NewARC returns a new Adaptive Replacement Cache (ARC).
 func NewARC(c int, t1, t2, b1, b2 ListService, opts ...Option) CacheService {
 	arc := &ARC{
-		p:     0,
-		c:     c,
-		t1:    t1,
-		t2:    t2,
-		b1:    b1,
-		b2:    b2,
-		len:   0,
-		cache: make(map[interface{}]*entry, c),
+		p:         0,
+		c:         c,
+		t1:        t1,
+		t2:        t2,
+		b1:        b1,
+		b2:        b2,
+		len:       0,
+		cache:     make(map[interface{}]*entry, c),
+		tags:      make(map[string]map[interface{}]struct{}),
+		evictions: make(map[EvictionReason]uint64),
+		auditSize: defaultAuditSize,
+		pinnedCap: -1,
+		codec:     NewGobCodec(),
+
+		flushInterval: defaultFlushInterval,
 	}
+	// versions start from the clock so that they keep growing across restarts
+	arc.version = uint64(time.Now().UnixNano())
 	for _, o := range opts {
 		o(arc)
 	}
+	arc.startStore()
 
 	return arc
 }
@@ -74,47 +144,261 @@ func (a *ARC) Put(key, value interface{}) bool {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
+	a.trace(TracePut, key)
+	if !a.admits(key) {
+		a.reject(key)
+		a.storeValue(key, value)
+		return false
+	}
+	existed, _ := a.write(key, value, time.Time{})
+	return existed
+}
+
+// PutWithTTL inserts a new key-value pair into the cache which expires after ttl.
+// A ttl of zero or less means the entry never expires, as with Put.
+func (a *ARC) PutWithTTL(key, value interface{}, ttl time.Duration) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.trace(TracePut, key)
+	if !a.admits(key) {
+		a.reject(key)
+		a.storeValue(key, value)
+		return false
+	}
+	expires := expiry(ttl)
+	existed, _ := a.write(key, value, expires)
+	return existed
+}
+
+func (a *ARC) put(key, value interface{}, expires time.Time) bool {
 	ent, ok := a.cache[key]
+	if !ok || ent.ghost {
+		// the key may evict another, chosen by recency
+		a.flushReads()
+	} else {
+		a.applyReads()
+	}
 	if ok != true {
 		a.len++
 
 		ent = &entry{
-			key:   key,
-			value: value,
-			ghost: false,
+			key:     key,
+			value:   value,
+			ghost:   false,
+			expires: expires,
+			version: a.nextVersion(),
 		}
 
-		a.logger.Debug("Adding a new entry item to cache.", "item", fmt.Sprintf("%+v", ent))
+		a.debugItem("Adding a new entry item to cache.", ent)
 
 		a.req(ent)
 		a.cache[key] = ent
+		a.indexed(ent)
 	} else {
-		a.logger.Debug("Item found in cache, will adjust its position", "item_key", fmt.Sprintf("%s", key))
+		if a.debugging() {
+			a.logger.Debug("Item found in cache, will adjust its position", "item_key", fmt.Sprintf("%s", key))
+		}
 		if ent.ghost {
 			a.len++
 		}
 		ent.value = value
 		ent.ghost = false
+		ent.expires = expires
+		ent.version = a.nextVersion()
 		a.req(ent)
 	}
+	for _, s := range a.shadows {
+		s.Put(key)
+	}
 	return ok
 }
 
 // Get retrieves a previously via Set inserted entry.
 // This optimizes future access to this entry (side effect).
 func (a *ARC) Get(key interface{}) (value interface{}, ok bool) {
+	if a.reads != nil {
+		if value, ok := a.getBuffered(key); ok {
+			return value, true
+		}
+	}
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
+	a.trace(TraceGet, key)
+	for _, s := range a.shadows {
+		s.get(key)
+	}
+	return a.get(key)
+}
+
+func (a *ARC) get(key interface{}) (value interface{}, ok bool) {
+	a.applyReads()
+	if a.admission != nil {
+		a.admission.increment(key)
+	}
 	ent, ok := a.cache[key]
+	if ok && ent.ghost {
+		// hits are not logged, they only change the recency of the key
+		a.walAppend(walGet, key, nil, time.Time{}, "")
+	}
+	if ok && !ent.ghost && ent.expired(time.Now()) {
+		if a.debugging() {
+			a.logger.Debug("Item expired, removing it from cache", "item_key", fmt.Sprintf("%s", key))
+		}
+		from := a.listName(ent.ll)
+		a.remove(ent)
+		a.dropped(ent, from, string(EvictExpired))
+		a.evictions[EvictExpired]++
+		a.notify(Event{Type: EventDrop, Key: key, From: from, Reason: EvictExpired})
+		ok = false
+	}
 	if ok {
-		a.logger.Debug("Reading a value from cache, will adjust its position", "item_keu", fmt.Sprintf("%s", key))
-		a.req(ent)
+		if ent.ghost {
+			a.misses++
+		} else {
+			a.hits++
+		}
+		a.access(ent)
 		return ent.value, !ent.ghost
 	}
+	a.misses++
+	a.notify(Event{Type: EventMiss, Key: key})
 	return nil, false
 }
 
+// access moves an entry read from the cache as ARC does, the hit or miss being already counted
+func (a *ARC) access(ent *entry) {
+	if a.debugging() {
+		a.logger.Debug("Reading a value from cache, will adjust its position", "item_keu", fmt.Sprintf("%s", ent.key))
+	}
+	wasGhost, from := ent.ghost, a.listName(ent.ll)
+	a.req(ent)
+	if !wasGhost {
+		a.notify(Event{Type: EventHit, Key: ent.key, Case: 1, From: from, To: a.listName(ent.ll)})
+	}
+}
+
+// Touch sets the time to live of a cached key without affecting its position.
+// A ttl of zero or less removes the expiry. It reports whether the key was cached.
+func (a *ARC) Touch(key interface{}, ttl time.Duration) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	ent, ok := a.cache[key]
+	if !ok || ent.ghost || ent.expired(time.Now()) {
+		return false
+	}
+	ent.expires = expiry(ttl)
+	a.walAppend(walTouch, key, nil, ent.expires, "")
+	return true
+}
+
+// TTL returns the time left before a cached key expires, zero if it never does.
+// It reports whether the key was cached and does not affect its position.
+func (a *ARC) TTL(key interface{}) (time.Duration, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	ent, ok := a.cache[key]
+	now := time.Now()
+	if !ok || ent.ghost || ent.expired(now) {
+		return 0, false
+	}
+	if ent.expires.IsZero() {
+		return 0, true
+	}
+	return ent.expires.Sub(now), true
+}
+
+// Delete removes key from the cache, including any ghost entry kept for it in B1 or B2, and
+// from the store set with SetStore. It reports whether a cached value was removed.
+func (a *ARC) Delete(key interface{}) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.unstore(key)
+	return a.delete(key)
+}
+
+func (a *ARC) delete(key interface{}) bool {
+	a.dropLoads(key, false)
+	ent, ok := a.cache[key]
+	if !ok {
+		if l := a.ghostList(key); l != nil {
+			a.walAppend(walDelete, key, nil, time.Time{}, "")
+			a.fingerprints(l).remove(fingerprint(key))
+		}
+		return false
+	}
+	a.debugItem("Deleting item from cache", ent)
+	a.walAppend(walDelete, key, nil, time.Time{}, "")
+	from := a.listName(ent.ll)
+	a.remove(ent)
+	a.dropped(ent, from, "delete")
+	return !ent.ghost
+}
+
+// Purge empties the cache, dropping every entry of T1, T2, B1 and B2 and resetting p.
+// Ghost entries already stored in the database are left untouched.
+func (a *ARC) Purge() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.purge()
+}
+
+func (a *ARC) purge() {
+	a.logger.Debug("Purging cache", "len", a.len)
+	a.walAppend(walPurge, nil, nil, time.Time{}, "")
+	a.dropLoads(nil, true)
+	for _, ent := range a.cache {
+		from := a.listName(ent.ll)
+		ent.detach()
+		a.dropped(ent, from, "purge")
+	}
+	a.cache = make(map[interface{}]*entry, a.c)
+	a.resetIndexes()
+	if a.g1 != nil {
+		a.g1.reset()
+		a.g2.reset()
+	}
+	a.len = 0
+	a.pinned = 0
+	a.p = 0
+}
+
+// remove drops ent from its list and from the cache without leaving a ghost behind
+func (a *ARC) remove(ent *entry) {
+	ent.detach()
+	ent.ll = nil
+	delete(a.cache, ent.key)
+	a.unindexed(ent)
+	if !ent.ghost {
+		a.len--
+	}
+	if ent.pinned {
+		ent.pinned = false
+		a.pinned--
+	}
+}
+
+// Flush saves the dirty values of write-back to the store, returning a *StoreError for those
+// which failed, and writes out anything the database list service still holds in memory.
+func (a *ARC) Flush() error {
+	err := a.flushStore(true)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if f, ok := a.db.(FlushService); ok {
+		if ferr := f.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
 // Len determines the number of currently cached entries.
 // This method is side-effect free in the sense that it does not attempt to optimize random cache access.
 func (a *ARC) Len() int {
@@ -124,52 +408,115 @@ func (a *ARC) Len() int {
 	return a.len
 }
 
+// Stats returns the list sizes and the hit and miss counters of the cache along with those of its shadows.
+// Like Len it does not affect the position of any entry.
+func (a *ARC) Stats() Stats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.flushReads()
+	stats := Stats{
+		C:      a.c,
+		P:      a.p,
+		T1:     a.t1.Len(),
+		T2:     a.t2.Len(),
+		B1:     a.b1Len(),
+		B2:     a.b2Len(),
+		Pinned: a.pinned,
+		Hits:   a.hits,
+		Misses: a.misses,
+
+		GhostHitsB1: a.ghostHitsB1,
+		GhostHitsB2: a.ghostHitsB2,
+		Evictions:   make(map[EvictionReason]uint64, len(a.evictions)),
+		DBWrites:    a.dbWrites,
+		DBErrors:    a.dbErrors,
+		DBWriteTime: a.dbWriteTime,
+		Rejected:    a.rejected,
+		StaleHits:   a.staleHits,
+	}
+	if a.store != nil {
+		stats.StoreSaves, stats.StoreErrors = atomic.LoadUint64(&a.store.saves), atomic.LoadUint64(&a.store.errors)
+		stats.Dirty = len(a.store.dirty)
+	}
+	a.loadMutex.Lock()
+	stats.Loads, stats.LoadErrors = a.loads, a.loadErrors
+	a.loadMutex.Unlock()
+	if a.reads != nil {
+		stats.ReadsDropped = atomic.LoadUint64(&a.reads.dropped)
+	}
+	for reason, n := range a.evictions {
+		stats.Evictions[reason] = n
+	}
+	for _, s := range a.shadows {
+		stats.Shadows = append(stats.Shadows, s.stats())
+	}
+	return stats
+}
+
 func (a *ARC) req(ent *entry) {
-	if ent.ll == a.t1 || ent.ll == a.t2 {
-		a.logger.Debug("Case 1", "item", fmt.Sprintf("%+v", ent))
+	oldP := a.p
+	ll := ent.ll
+	if ll == nil {
+		// a new entry, unless compact ghost lists remember its key
+		ll = a.ghostList(ent.key)
+	}
+	from := a.listName(ll)
+	if ll == a.t1 || ll == a.t2 {
+		a.debugItem("Case 1", ent)
 		// repetitive entry so should go into MRU
 		// Case I
 		// x ∈ T1 ∪ T2 (a hit in ARC(c) and DBL(2c)): Move x to the top of T2
 		ent.setMRU(a.t2)
-	} else if ent.ll == a.b1 {
+	} else if ll == a.b1 {
 
-		a.logger.Debug("Case 2", "item", fmt.Sprintf("%+v", ent))
+		a.debugItem("Case 2", ent)
 		// Case II
 		// Cache Miss in t1 and t2
 		// x ∈ B1 (a miss in ARC(c), a hit in DBL(2c)):
 		// Adapt p = min{ c, p + max{ |B2| / |B1|, 1} }. REPLACE(p).
 		// Move x to the top of T2 and place it in the cache.
 		// Adaptation
+		a.ghostHitsB1++
 		var d int
-		if a.b1.Len() >= a.b2.Len() {
+		if a.b1Len() >= a.b2Len() {
 			d = 1
 		} else {
-			d = a.b2.Len() / a.b1.Len()
+			d = a.b2Len() / a.b1Len()
 		}
 		a.p = utils.Min(a.p+d, a.c)
+		a.notify(Event{Type: EventGhostHit, Key: ent.key, Case: 2, From: "b1"})
 
-		a.replace(ent)
+		a.replace(false)
+		if a.g1 != nil {
+			a.g1.remove(fingerprint(ent.key))
+		}
 		ent.setMRU(a.t2)
-	} else if ent.ll == a.b2 {
-		a.logger.Debug("Case 3", "item", fmt.Sprintf("%+v", ent))
+	} else if ll == a.b2 {
+		a.debugItem("Case 3", ent)
 		// Case III
 		// Cache Miss in t1 and t2
 		// x ∈ B2 (a miss in ARC(c), a hit in DBL(2c)):
 		// Adapt p = max{ 0, p – max{ |B1| / |B2|, 1} } . REPLACE(p).
 		// Move x to the top of T2 and place it in the cache.
 		// Adaptation
+		a.ghostHitsB2++
 		var d int
-		if a.b2.Len() >= a.b1.Len() {
+		if a.b2Len() >= a.b1Len() {
 			d = 1
 		} else {
-			d = a.b1.Len() / a.b2.Len()
+			d = a.b1Len() / a.b2Len()
 		}
 		a.p = utils.Max(a.p-d, 0)
+		a.notify(Event{Type: EventGhostHit, Key: ent.key, Case: 3, From: "b2"})
 
-		a.replace(ent)
+		a.replace(true)
+		if a.g2 != nil {
+			a.g2.remove(fingerprint(ent.key))
+		}
 		ent.setMRU(a.t2)
-	} else if ent.ll == nil {
-		a.logger.Debug("Case 4", "item", fmt.Sprintf("%+v", ent))
+	} else if ll == nil {
+		a.debugItem("Case 4", ent)
 		// Case IV
 		// x ∈ L1 ∪ L2 (a miss in DBL(2c) and ARC(c)):
 		// case (i) |L1| = c:
@@ -179,69 +526,144 @@ func (a *ARC) req(ent *entry) {
 		//   if |L1| + |L2|= 2c then delete the LRU page of B2.
 		//   REPLACE(p) .
 		// Put x at the top of T1 and place it in the cache.
-		if a.t1.Len()+a.b1.Len() == a.c {
+		if a.t1.Len()+a.b1Len() == a.c {
 			// Case A
 			if a.t1.Len() < a.c {
-				a.delLRU(a.b1)
-				if a.db != nil {
-					a.db.Remove("B1")
-				}
-				a.replace(ent)
+				a.delLRU(a.b1, EvictDropB1)
+				a.dbRemove("B1")
+				a.replace(false)
 			} else {
-				a.delLRU(a.t1)
+				a.delLRU(a.t1, EvictDropT1)
 			}
-		} else if a.t1.Len()+a.b1.Len() < a.c {
+		} else if a.t1.Len()+a.b1Len() < a.c {
 			// Case B
-			if a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() >= a.c {
-				if a.t1.Len()+a.t2.Len()+a.b1.Len()+a.b2.Len() == 2*a.c {
-					a.delLRU(a.b2)
-					if a.db != nil {
-						a.db.Remove("B2")
-					}
+			if a.t1.Len()+a.t2.Len()+a.b1Len()+a.b2Len() >= a.c {
+				if a.t1.Len()+a.t2.Len()+a.b1Len()+a.b2Len() == 2*a.c {
+					a.delLRU(a.b2, EvictDropB2)
+					a.dbRemove("B2")
 				}
-				a.replace(ent)
+				a.replace(false)
 			}
 		}
 		ent.setMRU(a.t1)
 	}
-	a.logger.Debug("Adaptation value was", "p", a.p)
+	a.accessed(ent, from)
+	if a.p != oldP {
+		a.notify(Event{Type: EventPChange, Key: ent.key, OldP: oldP})
+	}
+	if a.debugging() {
+		a.logger.Debug("Adaptation value was", "p", a.p)
+	}
 }
 
-func (a *ARC) delLRU(l ListService) {
-	lru := l.Back()
-	a.logger.Debug("Removing item from list", "item", fmt.Sprintf("%+v", lru))
+func (a *ARC) delLRU(l ListService, reason EvictionReason) {
+	if a.g1 != nil && (l == a.b1 || l == a.b2) {
+		fp := a.fingerprints(l).removeLRU()
+		a.logger.Debug("Removing fingerprint from list", "fingerprint", fingerprintKey(fp))
+		a.evictions[reason]++
+		a.notify(Event{Type: EventGhostDrop, Key: fingerprintKey(fp), From: a.listName(l), Reason: reason})
+		return
+	}
+	lru := lruUnpinned(l)
+	if lru == nil {
+		return
+	}
+	if a.debugging() {
+		a.logger.Debug("Removing item from list", "item", fmt.Sprintf("%+v", lru.Value))
+	}
 	l.Remove(lru)
-	a.len--
-	delete(a.cache, lru.Value.(*entry).key)
+	ent := lru.Value.(*entry)
+	if !ent.ghost {
+		a.writeBack(ent.key)
+		a.len--
+	}
+	delete(a.cache, ent.key)
+	a.unindexed(ent)
+	a.dropped(ent, a.listName(l), string(reason))
+	a.walAppend(walEvict, ent.key, nil, time.Time{}, reason)
+	a.evictions[reason]++
+
+	typ := EventDrop
+	if ent.ghost {
+		typ = EventGhostDrop
+	}
+	a.notify(Event{Type: typ, Key: ent.key, From: a.listName(l), Reason: reason})
 }
 
-func (a *ARC) replace(ent *entry) {
+func (a *ARC) replace(inB2 bool) {
 	// if (|T1| ≥ 1) and ((x ∈ B2 and |T1| = p) or (|T1| > p))
 	//   then move the LRU page of T1 to the top of B1 and remove it from the cache.
 	// else move the LRU page in T2 to the top of B2 and remove it from the cache.
-	if a.t1.Len() > 0 && ((a.t1.Len() > a.p) || (ent.ll == a.b2 && a.t1.Len() == a.p)) {
-		lru := a.t1.Back().Value.(*entry)
-		a.logger.Debug("Moving item from T1 to B1", "item", fmt.Sprintf("%+v", lru))
-		lru.value = nil
-		lru.ghost = true
-		a.len--
-		lru.setMRU(a.b1)
+	// Pinned pages are skipped, and nothing is replaced while Delete or expiries keep the
+	// cache from being full.
+	if !a.full() {
+		return
+	}
+	lru := a.replaceVictim(inB2)
+	if lru == nil {
+		return
+	}
+	a.writeBack(lru.key)
+	if lru.ll == a.t1 {
+		a.debugItem("Moving item from T1 to B1", lru)
+		a.ghost(lru, a.b1)
+		a.demoted(lru, "t1", "b1", EvictDemoteT1)
+		a.walAppend(walEvict, lru.key, nil, time.Time{}, EvictDemoteT1)
+		a.evictions[EvictDemoteT1]++
+		a.notify(Event{Type: EventDemotion, Key: lru.key, From: "t1", To: "b1", Reason: EvictDemoteT1})
 		// Archieve  Evicted items to database
-		if a.db != nil {
-			a.db.PushFront("B1", lru.key, lru.value)
-		}
+		a.dbPush("B1", lru.key, lru.value)
 
 	} else {
-		lru := a.t2.Back().Value.(*entry)
-		a.logger.Debug("Moving item from T2 to B2", "item", fmt.Sprintf("%+v", lru))
-		lru.value = nil
-		lru.ghost = true
-		a.len--
-		lru.setMRU(a.b2)
+		a.debugItem("Moving item from T2 to B2", lru)
+		a.ghost(lru, a.b2)
+		a.demoted(lru, "t2", "b2", EvictDemoteT2)
+		a.walAppend(walEvict, lru.key, nil, time.Time{}, EvictDemoteT2)
+		a.evictions[EvictDemoteT2]++
+		a.notify(Event{Type: EventDemotion, Key: lru.key, From: "t2", To: "b2", Reason: EvictDemoteT2})
 		// Archieve  Evicted items to database
-		if a.db != nil {
-			a.db.PushFront("B2", lru.key, lru.value)
-		}
+		a.dbPush("B2", lru.key, lru.value)
+	}
+}
+
+// full reports whether T1 and T2 hold c entries
+func (a *ARC) full() bool {
+	return a.t1.Len()+a.t2.Len() >= a.c
+}
+
+// dbPush saves a ghost entry of a list to the database, at the end of the batch when batching
+func (a *ARC) dbPush(listID string, key, value interface{}) {
+	if a.db == nil {
+		return
+	}
+	if a.batching {
+		a.dbBatch = append(a.dbBatch, DBOp{ListID: listID, Key: key, Value: value})
+		return
+	}
+	a.dbWrite(func() error { return a.db.PushFront(listID, key, value) })
+}
+
+// dbRemove drops a ghost entry of a list from the database, at the end of the batch when batching
+func (a *ARC) dbRemove(listID string) {
+	if a.db == nil {
+		return
+	}
+	if a.batching {
+		a.dbBatch = append(a.dbBatch, DBOp{ListID: listID, Remove: true})
+		return
+	}
+	a.dbWrite(func() error { return a.db.Remove(listID) })
+}
+
+// dbWrite runs a write of the database list service, recording its latency and outcome
+func (a *ARC) dbWrite(write func() error) {
+	start := time.Now()
+	err := write()
+	a.dbWriteTime += time.Since(start)
+	a.dbWrites++
+	if err != nil {
+		a.dbErrors++
+		a.logger.Error("Unable to write ghost list to database", "err", err)
 	}
 }
 
@@ -265,10 +687,16 @@ func (a *ARC) Traverse() {
 		fmt.Printf("%s -> %s\n", e.Value.(*entry).key, e.Value.(*entry).value)
 	}
 	fmt.Println("\nB1 items are")
+	if a.g1 != nil {
+		a.g1.each(func(fp uint64) { fmt.Println(fingerprintKey(fp)) })
+	}
 	for e := a.b1.Front(); e != nil; e = e.Next() {
 		fmt.Printf("%s -> %s\n", e.Value.(*entry).key, e.Value.(*entry).value)
 	}
 	fmt.Println("\nB2 items are")
+	if a.g2 != nil {
+		a.g2.each(func(fp uint64) { fmt.Println(fingerprintKey(fp)) })
+	}
 	for e := a.b2.Front(); e != nil; e = e.Next() {
 		fmt.Printf("%s -> %s\n", e.Value.(*entry).key, e.Value.(*entry).value)
 	}
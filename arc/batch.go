@@ -0,0 +1,94 @@
+package arc
+
+import "time"
+
+// DBOp is a write of the database list service, a PushFront of Key and Value to the list
+// or a Remove from it
+type DBOp struct {
+	ListID string
+	Remove bool
+	Key    interface{}
+	Value  interface{}
+}
+
+// GetMany retrieves the values of keys taking the lock once. The values found are returned
+// by key, missing keys are absent. Every key counts as a Get for its position.
+func (a *ARC) GetMany(keys []interface{}) map[interface{}]interface{} {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.startBatch()
+	defer a.endBatch()
+	values := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		a.trace(TraceGet, key)
+		for _, s := range a.shadows {
+			s.get(key)
+		}
+		if value, ok := a.get(key); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// PutMany inserts the key-value pairs of entries taking the lock once. It reports by key
+// whether the key already held a value, like Put, and returns a *StoreError for the values
+// write-through failed to save, which are not cached. It is not atomic, the other values are
+// saved and cached all the same.
+func (a *ARC) PutMany(entries map[interface{}]interface{}) (map[interface{}]bool, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.startBatch()
+	defer a.endBatch()
+	existed := make(map[interface{}]bool, len(entries))
+	var errs map[interface{}]error
+	for key, value := range entries {
+		a.trace(TracePut, key)
+		var err error
+		if a.admits(key) {
+			existed[key], err = a.write(key, value, time.Time{})
+		} else {
+			a.reject(key)
+			existed[key], err = false, a.storeValue(key, value)
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make(map[interface{}]error)
+			}
+			errs[key] = err
+		}
+	}
+	if errs != nil {
+		return existed, &StoreError{Errors: errs}
+	}
+	return existed, nil
+}
+
+// startBatch holds back the database writes until endBatch
+func (a *ARC) startBatch() {
+	a.batching = true
+}
+
+// endBatch sends the database writes of the batch, in a single round trip when the
+// database list service implements BatchDBService
+func (a *ARC) endBatch() {
+	ops := a.dbBatch
+	a.dbBatch, a.batching = nil, false
+	if len(ops) == 0 {
+		return
+	}
+	if b, ok := a.db.(BatchDBService); ok {
+		a.dbWrite(func() error { return b.Batch(ops) })
+		return
+	}
+	for _, op := range ops {
+		op := op
+		if op.Remove {
+			a.dbWrite(func() error { return a.db.Remove(op.ListID) })
+		} else {
+			a.dbWrite(func() error { return a.db.PushFront(op.ListID, op.Key, op.Value) })
+		}
+	}
+}
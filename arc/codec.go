@@ -0,0 +1,49 @@
+package arc
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec turns the keys and values of the cache into bytes for Save and back for Load
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(b []byte) (interface{}, error)
+}
+
+// SetCodec function to set the codec used by Save and Load, gob by default
+func SetCodec(c Codec) func(*ARC) {
+	return func(arc *ARC) {
+		arc.codec = c
+	}
+}
+
+// gobCodec encodes with encoding/gob. Builtin types work as is,
+// other types must be registered with gob.Register on both ends.
+type gobCodec struct{}
+
+// gobValue carries the value as an interface so that gob records its type
+type gobValue struct {
+	V interface{}
+}
+
+// NewGobCodec returns a codec based on encoding/gob
+func NewGobCodec() Codec {
+	return gobCodec{}
+}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobValue{V: v}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(b []byte) (interface{}, error) {
+	var v gobValue
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.V, nil
+}
@@ -0,0 +1,192 @@
+package arc
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotNumber is returned by the counters for a value which does not hold a number
+	ErrNotNumber = errors.New("arc: value is not a number")
+	// ErrOverflow is returned by Increment and Decrement when the result does not fit in an int64
+	ErrOverflow = errors.New("arc: increment or decrement would overflow")
+)
+
+// Update replaces the value of key with the result of fn, atomically. fn is given the value
+// cached at key, or ok false when there is none, and nothing is stored when it returns an error.
+// The expiry of the key is kept, and it counts as a Put for the position of the key. The error
+// of a store failing to save the value with write-through is returned too.
+func (a *ARC) Update(key interface{}, fn func(value interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.update(key, fn)
+}
+
+func (a *ARC) update(key interface{}, fn func(value interface{}, ok bool) (interface{}, error)) (interface{}, error) {
+	var old interface{}
+	var expires time.Time
+	ent, ok := a.live(key)
+	if ok {
+		old, expires = ent.value, ent.expires
+	}
+	value, err := fn(old, ok)
+	if err != nil {
+		return nil, err
+	}
+	a.trace(TracePut, key)
+	if _, err := a.write(key, value, expires); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Increment adds delta to the integer cached at key and returns the result. A missing key
+// counts as 0. Byte slices and strings are read as decimal text and stay text, other values
+// must be integers and are stored back as an int64.
+func (a *ARC) Increment(key interface{}, delta int64) (int64, error) {
+	var n int64
+	_, err := a.Update(key, func(value interface{}, ok bool) (interface{}, error) {
+		if ok {
+			var err error
+			if n, err = toInt(value); err != nil {
+				return nil, err
+			}
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return nil, ErrOverflow
+		}
+		n += delta
+		switch value.(type) {
+		case []byte:
+			return []byte(strconv.FormatInt(n, 10)), nil
+		case string:
+			return strconv.FormatInt(n, 10), nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Decrement subtracts delta from the integer cached at key, like Increment
+func (a *ARC) Decrement(key interface{}, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return a.Increment(key, -delta)
+}
+
+// IncrementFloat adds delta to the number cached at key and returns the result. A missing key
+// counts as 0. Byte slices and strings stay text, other values are stored back as a float64.
+func (a *ARC) IncrementFloat(key interface{}, delta float64) (float64, error) {
+	var f float64
+	_, err := a.Update(key, func(value interface{}, ok bool) (interface{}, error) {
+		if ok {
+			var err error
+			if f, err = toFloat(value); err != nil {
+				return nil, err
+			}
+		}
+		f += delta
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrNotNumber
+		}
+		switch value.(type) {
+		case []byte:
+			return []byte(strconv.FormatFloat(f, 'f', -1, 64)), nil
+		case string:
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		return f, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return f, nil
+}
+
+// DecrementFloat subtracts delta from the number cached at key, like IncrementFloat
+func (a *ARC) DecrementFloat(key interface{}, delta float64) (float64, error) {
+	return a.IncrementFloat(key, -delta)
+}
+
+// toInt reads the integers stored by the counters or written as text by the front-ends
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		return int64(v), nil
+	case []byte:
+		return parseInt(string(v))
+	case string:
+		return parseInt(v)
+	}
+	return 0, ErrNotNumber
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return 0, ErrOverflow
+		}
+		return 0, ErrNotNumber
+	}
+	return n, nil
+}
+
+// toFloat reads the numbers stored by the counters or written as text by the front-ends
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case []byte:
+		return parseFloat(string(v))
+	case string:
+		return parseFloat(v)
+	}
+	n, err := toInt(value)
+	if err != nil {
+		return 0, ErrNotNumber
+	}
+	return float64(n), nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotNumber
+	}
+	return f, nil
+}
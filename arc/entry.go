@@ -2,14 +2,42 @@ package arc
 
 import (
 	"container/list"
+	"fmt"
+	"time"
 )
 
 type entry struct {
-	key   interface{}
-	value interface{}
-	ll    ListService
-	el    *list.Element
-	ghost bool
+	key     interface{}
+	value   interface{}
+	ll      ListService
+	el      *list.Element
+	ghost   bool
+	expires time.Time
+	// version changes whenever a value is stored, for CompareAndSwap
+	version uint64
+	// pinned entries are skipped by REPLACE and Case IV
+	pinned bool
+	// tags are set by PutWithTags for InvalidateTag
+	tags []string
+
+	// inserted, accessed, accesses and last are reported by Inspect
+	inserted time.Time
+	accessed time.Time
+	accesses uint64
+	last     Transition
+}
+
+// expired reports whether the entry had a time to live which has passed at now
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// expiry returns the expiration time for a time to live, the zero time meaning never
+func expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
 }
 
 func (e *entry) setLRU(l interface{}) {
@@ -40,3 +68,26 @@ func (e *entry) detach() {
 		e.ll.Remove(e.el)
 	}
 }
+
+// debugging reports whether the logger writes debug messages, loggers which do not tell always do
+func (a *ARC) debugging() bool {
+	return a.debug == nil || a.debug.DebugEnabled()
+}
+
+// debugItem logs msg at debug level with the fields of an entry, which are only formatted when
+// the logger writes it
+func (a *ARC) debugItem(msg string, e *entry) {
+	if a.debugging() {
+		a.logger.Debug(msg, logItem(e)...)
+	}
+}
+
+// logItem returns the log fields of an entry: item prints it whole, and item_key and item_value
+// are read back by logreplay
+func logItem(e *entry) []interface{} {
+	return []interface{}{
+		"item", fmt.Sprintf("%+v", e),
+		"item_key", fmt.Sprintf("%s", e.key),
+		"item_value", fmt.Sprintf("%v", e.value),
+	}
+}
@@ -0,0 +1,206 @@
+package arc
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies what the cache did
+type EventType string
+
+const (
+	// EventHit is a Get served from T1 or T2 (Case I)
+	EventHit EventType = "hit"
+	// EventMiss is a Get for a key absent from the cache
+	EventMiss EventType = "miss"
+	// EventGhostHit is an access to a key remembered in B1 (Case II) or B2 (Case III)
+	EventGhostHit EventType = "ghost_hit"
+	// EventDemotion is a REPLACE moving the LRU entry of T1 to B1 or of T2 to B2
+	EventDemotion EventType = "demotion"
+	// EventGhostDrop is Case IV forgetting the LRU ghost of B1 or B2
+	EventGhostDrop EventType = "ghost_drop"
+	// EventDrop is an entry leaving the cache without becoming a ghost, from T1 in Case IV or on expiry
+	EventDrop EventType = "drop"
+	// EventPChange is an adaptation of the target size of T1
+	EventPChange EventType = "p_change"
+)
+
+// Event describes a single transition of the cache
+type Event struct {
+	Type EventType   `json:"type"`
+	Time time.Time   `json:"time"`
+	Key  interface{} `json:"key,omitempty"`
+	// Case is the case of req handling the access, 1 to 4
+	Case int `json:"case,omitempty"`
+	// From and To are the lists the entry moved between, "t1", "t2", "b1" or "b2"
+	From   string         `json:"from,omitempty"`
+	To     string         `json:"to,omitempty"`
+	Reason EvictionReason `json:"reason,omitempty"`
+	// P is the target size of T1 after the event and OldP its value before a p_change
+	P    int `json:"p"`
+	OldP int `json:"old_p,omitempty"`
+}
+
+// Observer receives the events of a cache. Notify is called with the cache locked,
+// so it must be quick and must not call back into the cache.
+type Observer interface {
+	Notify(e Event)
+}
+
+// SetObserver function to subscribe an observer to the events of the cache, it can be given several times
+func SetObserver(o Observer) func(*ARC) {
+	return func(arc *ARC) {
+		arc.observers = append(arc.observers, o)
+	}
+}
+
+func (a *ARC) notify(e Event) {
+	if len(a.observers) == 0 {
+		return
+	}
+	e.Time = time.Now()
+	e.P = a.p
+	for _, o := range a.observers {
+		o.Notify(e)
+	}
+}
+
+// listName names the list l for events
+func (a *ARC) listName(l ListService) string {
+	switch l {
+	case a.t1:
+		return "t1"
+	case a.t2:
+		return "t2"
+	case a.b1:
+		return "b1"
+	case a.b2:
+		return "b2"
+	}
+	return ""
+}
+
+// EventBus fans the events of a cache out to in-process subscribers.
+// Subscribers which do not keep up miss events rather than slowing the cache down.
+type EventBus struct {
+	// dropped comes first to be 64-bit aligned for atomic access
+	dropped     uint64
+	mutex       sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewEventBus returns a bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Notify sends e to every subscriber with room left in its buffer
+func (b *EventBus) Notify(e Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			atomic.AddUint64(&b.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of events subscribers missed because their buffer was full
+func (b *EventBus) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Subscribe returns a channel receiving the events, buffering up to size of them,
+// and a function to call once done which closes the channel.
+func (b *EventBus) Subscribe(size int) (<-chan Event, func()) {
+	ch := make(chan Event, size)
+	b.mutex.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, ch)
+			b.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// jsonObserverBuffer is the number of events a JSONObserver holds while its writer catches up
+const jsonObserverBuffer = 4096
+
+// JSONObserver writes events as JSON lines from its own goroutine, so that the cache never
+// waits for the writer. Events arriving while its buffer is full are dropped.
+type JSONObserver struct {
+	// dropped comes first to be 64-bit aligned for atomic access
+	dropped uint64
+	mutex   sync.RWMutex
+	closed  bool
+	events  chan Event
+	done    chan struct{}
+	err     error
+}
+
+// NewJSONObserver returns an observer writing every event to w as a line of JSON, Close
+// writes the events still buffered
+func NewJSONObserver(w io.Writer) *JSONObserver {
+	o := &JSONObserver{
+		events: make(chan Event, jsonObserverBuffer),
+		done:   make(chan struct{}),
+	}
+	go o.write(json.NewEncoder(w))
+	return o
+}
+
+// Notify buffers e for the writer, events notified after Close are ignored
+func (o *JSONObserver) Notify(e Event) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	if o.closed {
+		return
+	}
+	select {
+	case o.events <- e:
+	default:
+		atomic.AddUint64(&o.dropped, 1)
+	}
+}
+
+// Dropped returns the number of events missed because the buffer was full
+func (o *JSONObserver) Dropped() uint64 {
+	return atomic.LoadUint64(&o.dropped)
+}
+
+// Close waits for the buffered events to be written and returns the first error of the writer
+func (o *JSONObserver) Close() error {
+	o.mutex.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.events)
+	}
+	o.mutex.Unlock()
+
+	<-o.done
+	return o.err
+}
+
+func (o *JSONObserver) write(enc *json.Encoder) {
+	defer close(o.done)
+	for e := range o.events {
+		if err := enc.Encode(e); err != nil && o.err == nil {
+			o.err = err
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package arc
+
+import "fmt"
+
+// fingerprints is a ghost list keeping 64-bit hashes of the keys instead of entries, ordered
+// from LRU to MRU. Removed fingerprints leave a zero slot behind until the slots are compacted.
+type fingerprints struct {
+	slots []uint64
+	// first is the index of the LRU slot, base the position of slots[0]
+	first int
+	base  int
+	pos   map[uint64]int
+	n     int
+}
+
+// SetCompactGhosts function to keep B1 and B2 as 64-bit fingerprints of the evicted keys rather
+// than as entries, so that remembering c ghosts costs a few bytes each whatever the size of the
+// keys. Two keys with the same fingerprint are the same ghost: a new key taken for a ghost goes
+// straight to T2 and moves p, which happens with a chance of about |B1 ∪ B2| in 2^64 per insert.
+func SetCompactGhosts(on bool) func(*ARC) {
+	return func(arc *ARC) {
+		arc.g1, arc.g2 = nil, nil
+		if on {
+			arc.g1, arc.g2 = newFingerprints(), newFingerprints()
+		}
+	}
+}
+
+func newFingerprints() *fingerprints {
+	return &fingerprints{pos: make(map[uint64]int)}
+}
+
+// fingerprint returns the hash of key kept by compact ghost lists, zero marks removed slots
+func fingerprint(key interface{}) uint64 {
+	if fp := hashKey(key); fp != 0 {
+		return fp
+	}
+	return 1
+}
+
+// fingerprintKey is the key a fingerprint is shown with in snapshots and events
+func fingerprintKey(fp uint64) string {
+	return fmt.Sprintf("#%016x", fp)
+}
+
+func (f *fingerprints) Len() int {
+	return f.n
+}
+
+func (f *fingerprints) contains(fp uint64) bool {
+	_, ok := f.pos[fp]
+	return ok
+}
+
+// pushFront adds fp as the MRU ghost
+func (f *fingerprints) pushFront(fp uint64) {
+	f.remove(fp)
+	f.slots = append(f.slots, fp)
+	f.pos[fp] = f.base + len(f.slots) - 1
+	f.n++
+}
+
+// remove drops fp, it reports whether it was there
+func (f *fingerprints) remove(fp uint64) bool {
+	i, ok := f.pos[fp]
+	if !ok {
+		return false
+	}
+	f.slots[i-f.base] = 0
+	delete(f.pos, fp)
+	f.n--
+	f.compact()
+	return true
+}
+
+// removeLRU drops and returns the LRU ghost, or 0 when there is none
+func (f *fingerprints) removeLRU() uint64 {
+	for f.first < len(f.slots) {
+		fp := f.slots[f.first]
+		f.slots[f.first] = 0
+		f.first++
+		if fp != 0 {
+			delete(f.pos, fp)
+			f.n--
+			f.compact()
+			return fp
+		}
+	}
+	return 0
+}
+
+// compact frees the slots of removed fingerprints once they are the majority
+func (f *fingerprints) compact() {
+	if len(f.slots) < 2*f.n+16 {
+		return
+	}
+	slots := make([]uint64, 0, 2*f.n+16)
+	f.base += len(f.slots)
+	for _, fp := range f.slots[f.first:] {
+		if fp != 0 {
+			f.pos[fp] = f.base + len(slots)
+			slots = append(slots, fp)
+		}
+	}
+	f.slots, f.first = slots, 0
+}
+
+// each calls fn with the fingerprints from MRU to LRU
+func (f *fingerprints) each(fn func(fp uint64)) {
+	for i := len(f.slots) - 1; i >= f.first; i-- {
+		if f.slots[i] != 0 {
+			fn(f.slots[i])
+		}
+	}
+}
+
+func (f *fingerprints) reset() {
+	*f = fingerprints{pos: make(map[uint64]int)}
+}
+
+// ghostList returns B1 or B2 when compact ghost lists remember key, nil otherwise
+func (a *ARC) ghostList(key interface{}) ListService {
+	if a.g1 == nil {
+		return nil
+	}
+	fp := fingerprint(key)
+	if a.g1.contains(fp) {
+		return a.b1
+	}
+	if a.g2.contains(fp) {
+		return a.b2
+	}
+	return nil
+}
+
+// fingerprints returns the compact ghost list standing for B1 or B2
+func (a *ARC) fingerprints(l ListService) *fingerprints {
+	if l == a.b1 {
+		return a.g1
+	}
+	return a.g2
+}
+
+// b1Len and b2Len are the sizes of B1 and B2, whichever way the ghosts are kept
+func (a *ARC) b1Len() int {
+	if a.g1 != nil {
+		return a.g1.Len()
+	}
+	return a.b1.Len()
+}
+
+func (a *ARC) b2Len() int {
+	if a.g2 != nil {
+		return a.g2.Len()
+	}
+	return a.b2.Len()
+}
+
+// ghost moves ent from T1 or T2 to the ghost list l, leaving only a fingerprint of its key
+// with compact ghost lists
+func (a *ARC) ghost(ent *entry, l ListService) {
+	ent.value = nil
+	a.len--
+	if a.g1 != nil {
+		ent.detach()
+		ent.ll = nil
+		delete(a.cache, ent.key)
+		a.unindexed(ent)
+		a.fingerprints(l).pushFront(fingerprint(ent.key))
+		return
+	}
+	ent.ghost = true
+	ent.setMRU(l)
+}
+
+// snapshotFingerprints lists a compact ghost list like snapshotList
+func snapshotFingerprints(f *fingerprints) []SnapshotEntry {
+	entries := make([]SnapshotEntry, 0, f.Len())
+	f.each(func(fp uint64) {
+		entries = append(entries, SnapshotEntry{Key: fingerprintKey(fp)})
+	})
+	return entries
+}
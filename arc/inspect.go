@@ -0,0 +1,133 @@
+package arc
+
+import "time"
+
+// defaultAuditSize is the number of dropped keys Inspect remembers by default
+const defaultAuditSize = 128
+
+// Transition is the last move of an entry between lists
+type Transition struct {
+	Time time.Time `json:"time"`
+	// From and To are "t1", "t2", "b1", "b2", or empty outside of the lists
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Case is the case of req moving the entry on an access, 1 to 4
+	Case int `json:"case,omitempty"`
+	// Reason is the EvictionReason of an eviction, or "delete" and "purge"
+	Reason string `json:"reason,omitempty"`
+	// P is the target size of T1 once the entry moved
+	P int `json:"p"`
+}
+
+// KeyInfo explains where a key is and how it got there
+type KeyInfo struct {
+	Key interface{} `json:"key"`
+	// List is "t1", "t2", "b1" or "b2", or empty once the key left the cache
+	List       string     `json:"list"`
+	Inserted   time.Time  `json:"inserted"`
+	LastAccess time.Time  `json:"last_access"`
+	Accesses   uint64     `json:"accesses"`
+	Expires    time.Time  `json:"expires"`
+	Pinned     bool       `json:"pinned"`
+	Tags       []string   `json:"tags,omitempty"`
+	Last       Transition `json:"last"`
+}
+
+// SetAuditSize function to set how many dropped keys Inspect remembers, 128 by default
+func SetAuditSize(n int) func(*ARC) {
+	return func(arc *ARC) {
+		arc.auditSize = n
+	}
+}
+
+// Inspect returns what the cache knows of key, without affecting its position.
+// Keys which left the cache are reported while they are among the last dropped ones,
+// it reports false for other keys.
+func (a *ARC) Inspect(key interface{}) (KeyInfo, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if ent, ok := a.cache[key]; ok {
+		return a.info(ent), true
+	}
+	// compact ghost lists only know the list of a ghost, the rest may be in the audit ring
+	ghost := a.listName(a.ghostList(key))
+	// newest first
+	n := len(a.audit)
+	for i := 1; i <= n; i++ {
+		info := a.audit[(a.auditNext-i+n)%n]
+		if info.Key == key {
+			info.List = ghost
+			return info, true
+		}
+	}
+	if ghost != "" {
+		return KeyInfo{Key: key, List: ghost}, true
+	}
+	return KeyInfo{}, false
+}
+
+func (a *ARC) info(ent *entry) KeyInfo {
+	return KeyInfo{
+		Key:        ent.key,
+		List:       a.listName(ent.ll),
+		Inserted:   ent.inserted,
+		LastAccess: ent.accessed,
+		Accesses:   ent.accesses,
+		Expires:    ent.expires,
+		Pinned:     ent.pinned,
+		Tags:       append([]string(nil), ent.tags...),
+		Last:       ent.last,
+	}
+}
+
+// accessed records an access to ent which req moved from the list named from
+func (a *ARC) accessed(ent *entry, from string) {
+	now := time.Now()
+	if ent.inserted.IsZero() {
+		ent.inserted = now
+	}
+	ent.accessed = now
+	ent.accesses++
+
+	c := 4
+	switch from {
+	case "t1", "t2":
+		c = 1
+	case "b1":
+		c = 2
+	case "b2":
+		c = 3
+	}
+	ent.last = Transition{Time: now, From: from, To: a.listName(ent.ll), Case: c, P: a.p}
+}
+
+// demoted records the move of ent from a list of the cache to its ghost list, in the audit
+// ring with compact ghost lists since ent left the cache
+func (a *ARC) demoted(ent *entry, from, to string, reason EvictionReason) {
+	ent.last = Transition{Time: time.Now(), From: from, To: to, Reason: string(reason), P: a.p}
+	if a.g1 != nil {
+		a.audited(ent)
+	}
+}
+
+// dropped records ent leaving the list named from and the cache, in the audit ring
+func (a *ARC) dropped(ent *entry, from, reason string) {
+	ent.last = Transition{Time: time.Now(), From: from, Reason: reason, P: a.p}
+	a.audited(ent)
+}
+
+// audited adds ent to the audit ring
+func (a *ARC) audited(ent *entry) {
+	if a.auditSize <= 0 {
+		return
+	}
+	info := a.info(ent)
+	info.List = ""
+	if len(a.audit) < a.auditSize {
+		a.audit = append(a.audit, info)
+	} else {
+		a.audit[a.auditNext] = info
+	}
+	a.auditNext = (a.auditNext + 1) % a.auditSize
+}
@@ -2,14 +2,123 @@ package arc
 
 import (
 	"container/list"
+	"io"
+	"time"
 )
 
 // CacheService is interface for ARC
 type CacheService interface {
 	Get(key interface{}) (value interface{}, ok bool)
 	Put(key, value interface{}) bool
+	Delete(key interface{}) bool
+	Purge()
 	Traverse()
+	Snapshot() Snapshot
 	Len() int
+	Stats() Stats
+}
+
+// ExpiryService is implemented by caches whose entries can be given a time to live
+type ExpiryService interface {
+	PutWithTTL(key, value interface{}, ttl time.Duration) bool
+	Touch(key interface{}, ttl time.Duration) bool
+	TTL(key interface{}) (time.Duration, bool)
+}
+
+// AdmissionService reports the writes a cache did not keep
+type AdmissionService interface {
+	TryPut(key, value interface{}, ttl time.Duration) (bool, error)
+	TryPutIfAbsent(key, value interface{}, ttl time.Duration) (bool, error)
+}
+
+// TraceService records the operations applied to a cache, to replay them later
+type TraceService interface {
+	Record(op string, key interface{})
+}
+
+// InspectService explains where a key is in the cache and how it got there
+type InspectService interface {
+	Inspect(key interface{}) (KeyInfo, bool)
+}
+
+// PersistService saves the content of a cache and loads it back, to restart warm
+type PersistService interface {
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+}
+
+// WALService records the changes of a cache to a write-ahead log to recover from a crash
+type WALService interface {
+	OpenWAL(cfg WALConfig) error
+	Compact() error
+	Close() error
+}
+
+// VersionService gives every value a version, to update keys without overwriting concurrent writes
+type VersionService interface {
+	GetWithVersion(key interface{}) (value interface{}, version uint64, ok bool)
+	Version(key interface{}) (uint64, bool)
+	CompareAndSwap(key interface{}, version uint64, value interface{}) (uint64, error)
+	PutIfAbsent(key, value interface{}) bool
+}
+
+// BatchService reads and writes several keys at once
+type BatchService interface {
+	GetMany(keys []interface{}) map[interface{}]interface{}
+	PutMany(entries map[interface{}]interface{}) (map[interface{}]bool, error)
+}
+
+// UpdateService replaces values with a function of the previous value, atomically
+type UpdateService interface {
+	Update(key interface{}, fn func(value interface{}, ok bool) (interface{}, error)) (interface{}, error)
+}
+
+// CounterService keeps numbers in a cache, updating them atomically
+type CounterService interface {
+	Increment(key interface{}, delta int64) (int64, error)
+	Decrement(key interface{}, delta int64) (int64, error)
+	IncrementFloat(key interface{}, delta float64) (float64, error)
+	DecrementFloat(key interface{}, delta float64) (float64, error)
+}
+
+// PinService keeps chosen keys from being evicted
+type PinService interface {
+	Pin(key interface{}) error
+	Unpin(key interface{}) bool
+}
+
+// TagService removes keys in bulk, by the tags they were put with or by prefix
+type TagService interface {
+	PutWithTags(key, value interface{}, ttl time.Duration, tags ...string) bool
+	InvalidateTag(tag string) int
+	InvalidatePrefix(prefix string) int
+}
+
+// LoadService loads the keys a cache misses, it returns ErrNoLoader when it has nothing to load from
+type LoadService interface {
+	GetOrLoad(key interface{}) (interface{}, error)
+}
+
+// ScanService lists the keys of a cache without changing their recency
+type ScanService interface {
+	Scan(cursor uint64, match string, count int) (keys []interface{}, next uint64)
+	Range(fn func(key, value interface{}) bool)
+}
+
+// StoreService stops the background saves of a cache backed by a store and saves what is left
+type StoreService interface {
+	CloseStore() error
+}
+
+// FlushService is implemented by services which hold writes in memory before persisting them
+type FlushService interface {
+	Flush() error
+}
+
+// DebugLogger is implemented by loggers which tell whether they write debug messages, so that the
+// cache does not format the fields of those they would drop
+type DebugLogger interface {
+	DebugEnabled() bool
 }
 
 // Logger is used for logging
@@ -30,6 +139,11 @@ type DBService interface {
 	PushFront(ListID string, key interface{}, value interface{}) error
 }
 
+// BatchDBService is implemented by database list services which apply several writes in one round trip
+type BatchDBService interface {
+	Batch(ops []DBOp) error
+}
+
 // EntryService is to allow ghost entries to be stored into the database
 type EntryService interface {
 	setLRU(l interface{})
@@ -0,0 +1,100 @@
+package arc
+
+import "sort"
+
+// keyIndexBucket is the number of keys of a bucket of the key index, which splits at twice that
+const keyIndexBucket = 256
+
+// keyIndex keeps the string keys of the cache sorted, in buckets so that inserting a key only
+// shifts the keys of its bucket
+type keyIndex struct {
+	buckets [][]string
+	n       int
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{}
+}
+
+func (x *keyIndex) Len() int {
+	return x.n
+}
+
+// bucket returns the index of the bucket k belongs in: the first one whose last key is not
+// before k, or the last one
+func (x *keyIndex) bucket(k string) int {
+	i := sort.Search(len(x.buckets), func(i int) bool {
+		b := x.buckets[i]
+		return b[len(b)-1] >= k
+	})
+	if i == len(x.buckets) && i > 0 {
+		i--
+	}
+	return i
+}
+
+func (x *keyIndex) insert(k string) {
+	if len(x.buckets) == 0 {
+		x.buckets = [][]string{{k}}
+		x.n++
+		return
+	}
+	i := x.bucket(k)
+	b := x.buckets[i]
+	j := sort.SearchStrings(b, k)
+	if j < len(b) && b[j] == k {
+		return
+	}
+	b = append(b, "")
+	copy(b[j+1:], b[j:])
+	b[j] = k
+	x.n++
+	if len(b) < 2*keyIndexBucket {
+		x.buckets[i] = b
+		return
+	}
+	// split, copying the upper half so that both halves can grow in place
+	upper := append(make([]string, 0, 2*keyIndexBucket), b[keyIndexBucket:]...)
+	x.buckets = append(x.buckets, nil)
+	copy(x.buckets[i+2:], x.buckets[i+1:])
+	x.buckets[i], x.buckets[i+1] = b[:keyIndexBucket], upper
+}
+
+func (x *keyIndex) remove(k string) {
+	if len(x.buckets) == 0 {
+		return
+	}
+	i := x.bucket(k)
+	b := x.buckets[i]
+	j := sort.SearchStrings(b, k)
+	if j == len(b) || b[j] != k {
+		return
+	}
+	copy(b[j:], b[j+1:])
+	b[len(b)-1] = ""
+	x.buckets[i] = b[:len(b)-1]
+	x.n--
+	if len(x.buckets[i]) == 0 {
+		x.buckets = append(x.buckets[:i], x.buckets[i+1:]...)
+	}
+}
+
+// ascend calls fn with the keys from the first one not before from, in order, until it returns false
+func (x *keyIndex) ascend(from string, fn func(k string) bool) {
+	if len(x.buckets) == 0 {
+		return
+	}
+	i := x.bucket(from)
+	j := sort.SearchStrings(x.buckets[i], from)
+	for ; i < len(x.buckets); i, j = i+1, 0 {
+		for _, k := range x.buckets[i][j:] {
+			if !fn(k) {
+				return
+			}
+		}
+	}
+}
+
+func (x *keyIndex) reset() {
+	*x = keyIndex{}
+}
@@ -0,0 +1,226 @@
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoLoader is returned by GetOrLoad on a cache created without SetLoader or SetStore
+var ErrNoLoader = errors.New("arc: no loader set")
+
+// Loader returns the value of a key missing from the cache and the time to live to cache it for,
+// zero or less for none
+type Loader func(key interface{}) (value interface{}, ttl time.Duration, err error)
+
+// load is a call of the loader, shared by the GetOrLoad calls waiting for the same key
+type load struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// since is the last version stored when the load started, values stored later are newer
+	// than the loaded one
+	since uint64
+	// deleted is set, with the cache locked, when the key is deleted or the cache purged during
+	// the load, the value loaded may be gone
+	deleted bool
+}
+
+// SetLoader function to load the keys GetOrLoad misses. Concurrent misses of a key share a
+// single call of the loader.
+func SetLoader(l Loader) func(*ARC) {
+	return func(arc *ARC) {
+		arc.loader = l
+	}
+}
+
+// SetRefreshAhead function to reload in the background the entries GetOrLoad hits less than
+// window before they expire, so that hot keys never expire. Disabled when window is 0 or less.
+func SetRefreshAhead(window time.Duration) func(*ARC) {
+	return func(arc *ARC) {
+		arc.refreshAhead = window
+	}
+}
+
+// SetStaleWhileRevalidate function to let GetOrLoad serve the entries which expired less than
+// window ago while a single background reload replaces them. Get, Scan and the other reads
+// still treat them as expired. Disabled when window is 0 or less.
+func SetStaleWhileRevalidate(window time.Duration) func(*ARC) {
+	return func(arc *ARC) {
+		arc.staleWindow = window
+	}
+}
+
+// GetOrLoad is Get calling the loader on a miss, caching the value it returns unless the
+// admission policy rejects it. With SetRefreshAhead and SetStaleWhileRevalidate, hits on
+// entries close to or just past their expiry start a reload in the background and return the
+// value cached. The errors of background reloads are logged and the old value kept.
+func (a *ARC) GetOrLoad(key interface{}) (interface{}, error) {
+	if a.loader == nil && a.store == nil {
+		return nil, ErrNoLoader
+	}
+
+	a.mutex.Lock()
+	a.trace(TraceGet, key)
+	for _, s := range a.shadows {
+		s.get(key)
+	}
+	now := time.Now()
+	since := a.version
+	if value, ok := a.getStale(key, now); ok {
+		a.mutex.Unlock()
+		a.refresh(key, since)
+		return value, nil
+	}
+	value, ok := a.get(key)
+	refresh := false
+	if ok && a.refreshAhead > 0 {
+		ent := a.cache[key]
+		refresh = !ent.expires.IsZero() && ent.expires.Sub(now) <= a.refreshAhead
+	}
+	a.mutex.Unlock()
+
+	if ok {
+		if refresh {
+			a.refresh(key, since)
+		}
+		return value, nil
+	}
+	l, first := a.startLoad(key, since)
+	if first {
+		a.runLoad(key, l, false)
+	} else {
+		<-l.done
+	}
+	return l.value, l.err
+}
+
+// getStale serves an entry which expired less than the stale window ago as a hit
+func (a *ARC) getStale(key interface{}, now time.Time) (interface{}, bool) {
+	ent, ok := a.cache[key]
+	if !ok || ent.ghost || !ent.expired(now) || !now.Before(ent.expires.Add(a.staleWindow)) {
+		return nil, false
+	}
+	a.applyReads()
+	if a.admission != nil {
+		a.admission.increment(key)
+	}
+	a.hits++
+	a.staleHits++
+	a.access(ent)
+	return ent.value, true
+}
+
+// refresh reloads key in the background, unless it is being loaded already
+func (a *ARC) refresh(key interface{}, since uint64) {
+	if l, first := a.startLoad(key, since); first {
+		go a.runLoad(key, l, true)
+	}
+}
+
+// startLoad returns the load of key in flight, or a new one started after version since when
+// first is true
+func (a *ARC) startLoad(key interface{}, since uint64) (l *load, first bool) {
+	a.loadMutex.Lock()
+	defer a.loadMutex.Unlock()
+
+	if l, ok := a.loading[key]; ok {
+		return l, false
+	}
+	if a.loading == nil {
+		a.loading = make(map[interface{}]*load)
+	}
+	l = &load{done: make(chan struct{}), since: since}
+	a.loading[key] = l
+	return l, true
+}
+
+// runLoad calls the loader and caches its value
+func (a *ARC) runLoad(key interface{}, l *load, reload bool) {
+	var ttl time.Duration
+	l.value, ttl, l.err = a.callLoader(key)
+	if l.err != nil {
+		a.logger.Warn("Loading item failed", "item_key", fmt.Sprintf("%s", key), "reload", reload, "err", l.err)
+	} else {
+		a.loaded(key, l, ttl, reload)
+	}
+
+	a.loadMutex.Lock()
+	delete(a.loading, key)
+	a.loads++
+	if l.err != nil {
+		a.loadErrors++
+	}
+	a.loadMutex.Unlock()
+	close(l.done)
+}
+
+// dropLoads keeps the loads in flight of key, or of every key when all is set, from caching
+// their value. It must be called with the write lock held.
+func (a *ARC) dropLoads(key interface{}, all bool) {
+	a.loadMutex.Lock()
+	defer a.loadMutex.Unlock()
+
+	if all {
+		for _, l := range a.loading {
+			l.deleted = true
+		}
+	} else if l, ok := a.loading[key]; ok {
+		l.deleted = true
+	}
+}
+
+// callLoader returns the value of write-back not saved yet for key, or calls the loader, or
+// loads the key from the store
+func (a *ARC) callLoader(key interface{}) (interface{}, time.Duration, error) {
+	if a.store != nil {
+		a.mutex.Lock()
+		value, dirty, err := a.stored(key)
+		a.mutex.Unlock()
+		if dirty {
+			return value, 0, err
+		}
+	}
+	if a.loader != nil {
+		return a.loader(key)
+	}
+	value, err := a.store.Load(key)
+	return value, 0, err
+}
+
+// loaded caches the value of a load like PutWithTTL, without saving it to the store. It is
+// dropped when the key was written or deleted since the load started, so that writes are not
+// undone and deleted keys not brought back, and a reload only replaces a value still cached and
+// not dirty, so that keys evicted meanwhile are not brought back either.
+func (a *ARC) loaded(key interface{}, l *load, ttl time.Duration, reload bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if l.deleted {
+		return
+	}
+	value := l.value
+	if ent, ok := a.cache[key]; ok && ent.version > l.since {
+		return
+	}
+	if reload {
+		if ent, ok := a.cache[key]; !ok || ent.ghost {
+			return
+		}
+		if a.store != nil {
+			if _, dirty := a.store.dirty[key]; dirty {
+				return
+			}
+		}
+	}
+	a.trace(TracePut, key)
+	if !reload && !a.admits(key) {
+		a.reject(key)
+		return
+	}
+	expires := expiry(ttl)
+	if err := a.walAppend(walPut, key, value, expires, ""); err != nil {
+		return
+	}
+	a.put(key, value, expires)
+}
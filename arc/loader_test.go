@@ -0,0 +1,59 @@
+package arc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// blockingLoader returns a loader which announces each call on started and waits for release
+// before returning the value "loaded"
+func blockingLoader(started chan<- interface{}, release <-chan struct{}) arc.Loader {
+	return func(key interface{}) (interface{}, time.Duration, error) {
+		started <- key
+		<-release
+		return "loaded", 0, nil
+	}
+}
+
+func TestLoadRacingWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *arc.ARC)
+		want  interface{}
+	}{
+		{"put", func(c *arc.ARC) { c.Put("k", "put") }, "put"},
+		{"delete", func(c *arc.ARC) { c.Delete("k") }, nil},
+		{"purge", func(c *arc.ARC) { c.Purge() }, nil},
+		{"none", func(c *arc.ARC) {}, "loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started, release := make(chan interface{}, 1), make(chan struct{})
+			c := newCache(10, arc.SetLoader(blockingLoader(started, release)))
+			done := make(chan error)
+			go func() {
+				_, err := c.GetOrLoad("k")
+				done <- err
+			}()
+			<-started
+			tt.write(c)
+			close(release)
+			if err := <-done; err != nil {
+				t.Fatalf("loading: %v", err)
+			}
+
+			v, ok := c.Get("k")
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("got %v, want the key to stay deleted", v)
+				}
+				return
+			}
+			if !ok || v != tt.want {
+				t.Fatalf("got %v, %v, want %v", v, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package arc
+
+import (
+	"container/list"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNamespaceExists is returned by Create for a name already in use
+	ErrNamespaceExists = errors.New("arc: namespace already exists")
+	// ErrBadNamespace is returned by Create for an empty name or one holding a slash
+	ErrBadNamespace = errors.New("arc: invalid namespace name")
+	// ErrBadQuota is returned by Create for a quota whose minimum is lower than 1 or above its maximum
+	ErrBadQuota = errors.New("arc: invalid namespace quota")
+	// ErrBudgetExceeded is returned when the minimum quotas of the namespaces exceed the budget
+	ErrBudgetExceeded = errors.New("arc: minimum quotas exceed the budget")
+)
+
+// Quota bounds the capacity of a namespace. Max 0 lets it grow to the whole budget.
+type Quota struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// NamespaceInfo describes a namespace of a registry
+type NamespaceInfo struct {
+	Name  string `json:"name"`
+	Quota Quota  `json:"quota"`
+	// C is the capacity the namespace currently has
+	C int `json:"c"`
+}
+
+// Namespaces is a registry of caches sharing a budget of entries, so that a noisy tenant only
+// evicts its own keys. Every namespace is an ARC of its own, adapting its own p, whose capacity
+// is its minimum quota plus an equal share of what the minimums leave of the budget, up to its
+// maximum. The capacities are rebalanced whenever a namespace is created or dropped, so the
+// caches of namespaces must not log to a write-ahead log, which cannot be resized.
+type Namespaces struct {
+	mutex  sync.Mutex
+	budget int
+	opts   []Option
+	spaces map[string]*namespace
+}
+
+type namespace struct {
+	cache *ARC
+	quota Quota
+}
+
+// NewNamespaces returns an empty registry sharing budget entries, opts are applied to the ARC of
+// every namespace. Options holding state, like a database list service, must not be shared.
+func NewNamespaces(budget int, opts ...Option) *Namespaces {
+	return &Namespaces{
+		budget: budget,
+		opts:   opts,
+		spaces: make(map[string]*namespace),
+	}
+}
+
+// Create adds a namespace and returns its cache, once the capacities are rebalanced
+func (n *Namespaces) Create(name string, quota Quota) (*ARC, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, ErrBadNamespace
+	}
+	if quota.Min < 1 || (quota.Max != 0 && quota.Max < quota.Min) {
+		return nil, ErrBadQuota
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if _, ok := n.spaces[name]; ok {
+		return nil, ErrNamespaceExists
+	}
+	if n.minimums()+quota.Min > n.budget {
+		return nil, ErrBudgetExceeded
+	}
+	cache := NewARC(quota.Min, list.New(), list.New(), list.New(), list.New(), n.opts...).(*ARC)
+	n.spaces[name] = &namespace{cache: cache, quota: quota}
+	n.rebalance()
+	return cache, nil
+}
+
+// Get returns the cache of a namespace
+func (n *Namespaces) Get(name string) (*ARC, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	ns, ok := n.spaces[name]
+	if !ok {
+		return nil, false
+	}
+	return ns.cache, true
+}
+
+// Drop removes a namespace, purging its cache, and hands its capacity to the others.
+// It reports whether the namespace existed.
+func (n *Namespaces) Drop(name string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	ns, ok := n.spaces[name]
+	if !ok {
+		return false
+	}
+	delete(n.spaces, name)
+	ns.cache.Purge()
+	n.rebalance()
+	return true
+}
+
+// List returns the namespaces sorted by name
+func (n *Namespaces) List() []NamespaceInfo {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	infos := make([]NamespaceInfo, 0, len(n.spaces))
+	for _, name := range n.names() {
+		ns := n.spaces[name]
+		infos = append(infos, NamespaceInfo{Name: name, Quota: ns.quota, C: ns.cache.capacity()})
+	}
+	return infos
+}
+
+// Budget returns the number of entries shared by the namespaces
+func (n *Namespaces) Budget() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.budget
+}
+
+// SetBudget changes the number of entries shared by the namespaces and rebalances them
+func (n *Namespaces) SetBudget(budget int) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.minimums() > budget {
+		return ErrBudgetExceeded
+	}
+	n.budget = budget
+	n.rebalance()
+	return nil
+}
+
+func (n *Namespaces) names() []string {
+	names := make([]string, 0, len(n.spaces))
+	for name := range n.spaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (n *Namespaces) minimums() int {
+	sum := 0
+	for _, ns := range n.spaces {
+		sum += ns.quota.Min
+	}
+	return sum
+}
+
+// rebalance gives every namespace its minimum quota and shares the rest of the budget equally
+// among those below their maximum, then resizes the caches whose capacity changed
+func (n *Namespaces) rebalance() {
+	names := n.names()
+	capacity := make(map[string]int, len(names))
+	spare := n.budget
+	for _, name := range names {
+		capacity[name] = n.spaces[name].quota.Min
+		spare -= capacity[name]
+	}
+	for spare > 0 {
+		var open []string
+		for _, name := range names {
+			if max := n.spaces[name].quota.Max; max == 0 || capacity[name] < max {
+				open = append(open, name)
+			}
+		}
+		if len(open) == 0 {
+			break
+		}
+		share := spare / len(open)
+		if share == 0 {
+			share = 1
+		}
+		for _, name := range open {
+			add := share
+			if max := n.spaces[name].quota.Max; max != 0 && capacity[name]+add > max {
+				add = max - capacity[name]
+			}
+			if add > spare {
+				add = spare
+			}
+			capacity[name] += add
+			spare -= add
+		}
+	}
+	for _, name := range names {
+		if cache := n.spaces[name].cache; cache.capacity() != capacity[name] {
+			cache.Resize(capacity[name])
+		}
+	}
+}
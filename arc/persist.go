@@ -0,0 +1,386 @@
+package arc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// The saved format starts with persistMagic and persistVersion, followed by uvarints for c and p
+// and the four lists T1, T2, B1 and B2 from MRU to LRU, each as an entry count then entries.
+// An entry is the encoded key and, outside of the ghost lists, the encoded value, both prefixed
+// with their length, then its expiry in Unix nanoseconds as a varint, 0 if it never expires.
+// The CRC-32 (IEEE) of everything before it ends the file, big endian.
+//
+// Since version 3, a uvarint of flags follows p and the entries of T1 and T2 end with a uvarint
+// which is 1 for pinned entries. With the persistCompact flag, like in version 2, B1 and B2 are
+// a count then 8 byte big endian fingerprints instead of entries. Since version 4 every entry
+// ends with its tags, a count then strings prefixed with their length.
+const (
+	persistMagic   = "ARC\x00"
+	persistVersion = 4
+)
+
+// persistCompact flags a cache saved with compact ghost lists
+const persistCompact = 1
+
+// maxPersistField bounds the length of a single key or value read by Load
+const maxPersistField = 64 << 20
+
+var (
+	// ErrBadSnapshot is returned by Load for data which is not a saved cache or is corrupted
+	ErrBadSnapshot = errors.New("arc: not a valid saved cache")
+	// ErrCapacityMismatch is returned by Load for a cache saved with another capacity
+	ErrCapacityMismatch = errors.New("arc: saved cache has a different capacity")
+)
+
+// Save writes c, p and the four lists of the cache to w, values encoded with the codec
+func (a *ARC) Save(w io.Writer) error {
+	a.syncReads()
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.save(w)
+}
+
+func (a *ARC) save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	pw := &persistWriter{w: io.MultiWriter(bw, crc)}
+
+	lists := []ListService{a.t1, a.t2, a.b1, a.b2}
+	var flags uint64
+	if a.g1 != nil {
+		flags |= persistCompact
+		lists = lists[:2]
+	}
+	pw.write([]byte(persistMagic))
+	pw.uvarint(persistVersion)
+	pw.uvarint(uint64(a.c))
+	pw.uvarint(uint64(a.p))
+	pw.uvarint(flags)
+	for _, l := range lists {
+		ghosts := l == a.b1 || l == a.b2
+		pw.uvarint(uint64(l.Len()))
+		for e := l.Front(); e != nil; e = e.Next() {
+			ent := e.Value.(*entry)
+			pw.field(a.codec.Encode(ent.key))
+			if !ghosts {
+				pw.field(a.codec.Encode(ent.value))
+			}
+			var expires int64
+			if !ent.expires.IsZero() {
+				expires = ent.expires.UnixNano()
+			}
+			pw.varint(expires)
+			if !ghosts {
+				var pinned uint64
+				if ent.pinned {
+					pinned = 1
+				}
+				pw.uvarint(pinned)
+			}
+			pw.uvarint(uint64(len(ent.tags)))
+			for _, t := range ent.tags {
+				pw.str(t)
+			}
+		}
+	}
+	if a.g1 != nil {
+		for _, f := range []*fingerprints{a.g1, a.g2} {
+			pw.uvarint(uint64(f.Len()))
+			f.each(func(fp uint64) {
+				binary.BigEndian.PutUint64(pw.buf[:8], fp)
+				pw.write(pw.buf[:8])
+			})
+		}
+	}
+	if pw.err != nil {
+		return pw.err
+	}
+	if err := binary.Write(bw, binary.BigEndian, crc.Sum32()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Load replaces the content of the cache with the one saved to r. The whole input is read and
+// checked before the cache is touched, which is left as is on error. Entries which expired since
+// they were saved are skipped, and ghost lists stored in the database are not updated.
+// Fingerprints saved by a cache with compact ghost lists are dropped by a cache without them.
+func (a *ARC) Load(r io.Reader) error {
+	saved, err := a.decode(r)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.restore(saved); err != nil {
+		return err
+	}
+	if a.wal != nil {
+		// the log holds changes to the previous content, start over from the loaded one
+		return a.compact()
+	}
+	return nil
+}
+
+// saved is the content of a cache read by decode
+type saved struct {
+	c, p  int
+	lists [4][]*entry
+	// fingerprints are B1 and B2 saved by a cache with compact ghost lists
+	fingerprints [2][]uint64
+}
+
+// decode reads a saved cache from r
+func (a *ARC) decode(r io.Reader) (*saved, error) {
+	crc := crc32.NewIEEE()
+	pr := &persistReader{r: bufio.NewReader(r), crc: crc}
+
+	magic := make([]byte, len(persistMagic))
+	pr.read(magic)
+	if pr.err != nil || string(magic) != persistMagic {
+		return nil, ErrBadSnapshot
+	}
+	v := pr.uvarint()
+	if pr.err == nil && (v < 1 || v > persistVersion) {
+		return nil, fmt.Errorf("arc: unsupported saved cache version %d", v)
+	}
+	c := int(pr.uvarint())
+	p := int(pr.uvarint())
+	var flags uint64
+	switch {
+	case v == 2:
+		flags = persistCompact
+	case v >= 3:
+		flags = pr.uvarint()
+	}
+	var lists [4][]*entry
+	var fps [2][]uint64
+	now := time.Now()
+	for i := range lists {
+		if i >= 2 && flags&persistCompact != 0 {
+			n := pr.uvarint()
+			b := make([]byte, 8)
+			for j := uint64(0); j < n && pr.err == nil; j++ {
+				pr.read(b)
+				fps[i-2] = append(fps[i-2], binary.BigEndian.Uint64(b))
+			}
+			continue
+		}
+		ghosts := i >= 2
+		n := pr.uvarint()
+		for j := uint64(0); j < n && pr.err == nil; j++ {
+			ent := &entry{ghost: ghosts}
+			ent.key = pr.field(a.codec)
+			if !ghosts {
+				ent.value = pr.field(a.codec)
+			}
+			if expires := pr.varint(); expires != 0 {
+				ent.expires = time.Unix(0, expires)
+			}
+			if !ghosts && v >= 3 {
+				ent.pinned = pr.uvarint() == 1
+			}
+			if v >= 4 {
+				n := pr.uvarint()
+				for k := uint64(0); k < n && pr.err == nil; k++ {
+					ent.tags = append(ent.tags, pr.str())
+				}
+			}
+			if ghosts || !ent.expired(now) {
+				lists[i] = append(lists[i], ent)
+			}
+		}
+	}
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	sum := crc.Sum32()
+	var stored uint32
+	if err := binary.Read(pr.r, binary.BigEndian, &stored); err != nil || stored != sum {
+		return nil, ErrBadSnapshot
+	}
+	return &saved{c: c, p: p, lists: lists, fingerprints: fps}, nil
+}
+
+// restore replaces the content of the cache with s
+func (a *ARC) restore(s *saved) error {
+	if s.c != a.c {
+		return ErrCapacityMismatch
+	}
+	now := time.Now()
+	for _, ent := range a.cache {
+		ent.detach()
+	}
+	a.cache = make(map[interface{}]*entry, a.c)
+	a.resetIndexes()
+	a.len = 0
+	a.pinned = 0
+	a.p = s.p
+	if a.g1 != nil {
+		a.g1.reset()
+		a.g2.reset()
+		for i, f := range []*fingerprints{a.g1, a.g2} {
+			// from LRU to MRU, the ghost entries of a cache without compact ghost lists first
+			for j := len(s.lists[i+2]) - 1; j >= 0; j-- {
+				f.pushFront(fingerprint(s.lists[i+2][j].key))
+			}
+			for j := len(s.fingerprints[i]) - 1; j >= 0; j-- {
+				f.pushFront(s.fingerprints[i][j])
+			}
+		}
+	} else if len(s.fingerprints[0])+len(s.fingerprints[1]) > 0 {
+		a.logger.Warn("Dropping the fingerprints of compact ghost lists", "b1", len(s.fingerprints[0]), "b2", len(s.fingerprints[1]))
+	}
+	for i, l := range []ListService{a.t1, a.t2, a.b1, a.b2} {
+		if i >= 2 && a.g1 != nil {
+			break
+		}
+		for _, ent := range s.lists[i] {
+			ent.inserted = now
+			ent.version = a.nextVersion()
+			ent.setLRU(l)
+			a.cache[ent.key] = ent
+			a.indexed(ent)
+			tags := ent.tags
+			ent.tags = nil
+			a.tag(ent.key, tags)
+			if !ent.ghost {
+				a.len++
+			}
+			if ent.pinned && a.pinned < a.maxPinned() {
+				a.pinned++
+			} else {
+				ent.pinned = false
+			}
+		}
+	}
+	return nil
+}
+
+// persistWriter keeps the first error of a sequence of writes
+type persistWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (pw *persistWriter) write(b []byte) {
+	if pw.err == nil {
+		_, pw.err = pw.w.Write(b)
+	}
+}
+
+func (pw *persistWriter) uvarint(v uint64) {
+	pw.write(pw.buf[:binary.PutUvarint(pw.buf[:], v)])
+}
+
+func (pw *persistWriter) varint(v int64) {
+	pw.write(pw.buf[:binary.PutVarint(pw.buf[:], v)])
+}
+
+func (pw *persistWriter) str(s string) {
+	pw.uvarint(uint64(len(s)))
+	pw.write([]byte(s))
+}
+
+func (pw *persistWriter) field(b []byte, err error) {
+	if err != nil {
+		if pw.err == nil {
+			pw.err = err
+		}
+		return
+	}
+	pw.uvarint(uint64(len(b)))
+	pw.write(b)
+}
+
+// persistReader keeps the first error of a sequence of reads, and the checksum of what it read
+type persistReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+func (pr *persistReader) read(b []byte) {
+	if pr.err != nil {
+		return
+	}
+	if _, pr.err = io.ReadFull(pr.r, b); pr.err != nil {
+		pr.err = ErrBadSnapshot
+		return
+	}
+	pr.crc.Write(b)
+}
+
+func (pr *persistReader) ReadByte() (byte, error) {
+	var b [1]byte
+	pr.read(b[:])
+	return b[0], pr.err
+}
+
+func (pr *persistReader) uvarint() uint64 {
+	if pr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(pr)
+	if err != nil && pr.err == nil {
+		pr.err = ErrBadSnapshot
+	}
+	return v
+}
+
+func (pr *persistReader) varint() int64 {
+	if pr.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(pr)
+	if err != nil && pr.err == nil {
+		pr.err = ErrBadSnapshot
+	}
+	return v
+}
+
+func (pr *persistReader) str() string {
+	n := pr.uvarint()
+	if pr.err != nil {
+		return ""
+	}
+	if n > maxPersistField {
+		pr.err = ErrBadSnapshot
+		return ""
+	}
+	b := make([]byte, n)
+	pr.read(b)
+	return string(b)
+}
+
+func (pr *persistReader) field(codec Codec) interface{} {
+	n := pr.uvarint()
+	if pr.err != nil {
+		return nil
+	}
+	if n > maxPersistField {
+		pr.err = ErrBadSnapshot
+		return nil
+	}
+	b := make([]byte, n)
+	pr.read(b)
+	if pr.err != nil {
+		return nil
+	}
+	v, err := codec.Decode(b)
+	if err != nil {
+		pr.err = fmt.Errorf("arc: unable to decode saved entry: %v", err)
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package arc_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	saved := newCache(3)
+	saved.Put("a", 1)
+	saved.Put("a", 2)
+	saved.Put("c", 3)
+	saved.PutWithTTL("b", "four", time.Hour)
+	saved.Put("d", 5)
+	if err := saved.Pin("d"); err != nil {
+		t.Fatalf("pinning: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := saved.Save(&buf); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+	data := buf.Bytes()
+
+	loaded := newCache(3)
+	if err := loaded.Load(bytes.NewReader(data)); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	if got, want := loaded.Snapshot(), saved.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("loaded %+v, want %+v", got, want)
+	}
+	if ttl, ok := loaded.TTL("b"); !ok || (ttl <= 59*time.Minute || ttl > time.Hour) {
+		t.Fatalf("got a ttl of %v for b, want about an hour", ttl)
+	}
+
+	if err := newCache(4).Load(bytes.NewReader(data)); err != arc.ErrCapacityMismatch {
+		t.Fatalf("loading into another capacity: got %v, want %v", err, arc.ErrCapacityMismatch)
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if err := loaded.Load(bytes.NewReader(corrupted)); err != arc.ErrBadSnapshot {
+		t.Fatalf("loading corrupted data: got %v, want %v", err, arc.ErrBadSnapshot)
+	}
+	if got, want := loaded.Snapshot(), saved.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("a failed load changed the cache to %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package arc
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPinLimit is returned by Pin when as many entries as the pinned capacity are pinned already
+var ErrPinLimit = errors.New("arc: pinned capacity reached")
+
+// SetPinnedCap function to set how many entries may be pinned at once, c/2 by default or when n
+// is negative. It is capped at c-1 so that REPLACE and Case IV always find an entry to evict.
+func SetPinnedCap(n int) func(*ARC) {
+	return func(arc *ARC) {
+		arc.pinnedCap = n
+	}
+}
+
+// Pin keeps the value cached at key from being evicted: REPLACE and Case IV skip it and evict
+// the next entry of the list instead. A pinned entry still moves between T1 and T2 and counts
+// in their sizes, so p adapts as usual, and it leaves the cache on Delete, Purge or once its
+// time to live passed. It returns ErrNotFound for keys which do not hold a value and
+// ErrPinLimit when the pinned capacity is reached.
+func (a *ARC) Pin(key interface{}) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err := a.pin(key); err != nil {
+		return err
+	}
+	a.walAppend(walPin, key, nil, time.Time{}, "")
+	return nil
+}
+
+func (a *ARC) pin(key interface{}) error {
+	ent, ok := a.live(key)
+	if !ok {
+		return ErrNotFound
+	}
+	if ent.pinned {
+		return nil
+	}
+	if a.pinned >= a.maxPinned() {
+		return ErrPinLimit
+	}
+	if a.debugging() {
+		a.logger.Debug("Pinning item", "item_key", fmt.Sprintf("%s", key))
+	}
+	ent.pinned = true
+	a.pinned++
+	return nil
+}
+
+// Unpin lets the entry of key be evicted again, it reports whether it was pinned
+func (a *ARC) Unpin(key interface{}) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if !a.unpin(key) {
+		return false
+	}
+	a.walAppend(walUnpin, key, nil, time.Time{}, "")
+	return true
+}
+
+func (a *ARC) unpin(key interface{}) bool {
+	ent, ok := a.cache[key]
+	if !ok || !ent.pinned {
+		return false
+	}
+	ent.pinned = false
+	a.pinned--
+	return true
+}
+
+// maxPinned is the pinned capacity, at most c-1
+func (a *ARC) maxPinned() int {
+	n := a.pinnedCap
+	if n < 0 {
+		n = a.c / 2
+	}
+	if n > a.c-1 {
+		n = a.c - 1
+	}
+	return n
+}
+
+// lruUnpinned returns the least recently used element of l which is not pinned, or nil
+func lruUnpinned(l ListService) *list.Element {
+	for e := l.Back(); e != nil; e = e.Prev() {
+		if !e.Value.(*entry).pinned {
+			return e
+		}
+	}
+	return nil
+}
+
+// replaceVictim returns the entry REPLACE moves to a ghost list: the LRU unpinned entry of T1
+// when T1 is over its target size, of T2 otherwise, or of the other list when it has none.
+func (a *ARC) replaceVictim(inB2 bool) *entry {
+	// if (|T1| ≥ 1) and ((x ∈ B2 and |T1| = p) or (|T1| > p)) the LRU page of T1, else of T2
+	lists := []ListService{a.t2, a.t1}
+	if a.t1.Len() > 0 && ((a.t1.Len() > a.p) || (inB2 && a.t1.Len() == a.p)) {
+		lists = []ListService{a.t1, a.t2}
+	}
+	for _, l := range lists {
+		if e := lruUnpinned(l); e != nil {
+			return e.Value.(*entry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package arc
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReadBatches is the number of full read buffers waiting for the write lock before
+// new ones are dropped
+const defaultReadBatches = 64
+
+// readStripes is the number of read buffers per CPU
+const readStripes = 4
+
+// reads records the hits served under the read lock until they are applied to T1 and T2
+type reads struct {
+	size int
+	// buffers are picked at random by the readers, so that they seldom share one. Unlike pooled
+	// buffers, they are never collected with the hits they hold.
+	buffers []readBuffer
+	// pending holds the full buffers until a goroutine gets the write lock
+	pending chan []*entry
+	dropped uint64
+}
+
+type readBuffer struct {
+	mutex   sync.Mutex
+	entries []*entry
+	// pad keeps the buffers on different cache lines
+	pad [32]byte
+}
+
+// SetReadBuffer function to serve the Get hits under a read lock, so that readers do not
+// contend with each other. The hits are recorded in buffers of size accesses and applied to
+// T1 and T2 later, under the write lock. A size of 0 or less keeps every Get under the write lock.
+func SetReadBuffer(size int) func(*ARC) {
+	return func(arc *ARC) {
+		if size <= 0 {
+			arc.reads = nil
+			return
+		}
+		r := &reads{
+			size:    size,
+			buffers: make([]readBuffer, readStripes*runtime.GOMAXPROCS(0)),
+			pending: make(chan []*entry, defaultReadBatches),
+		}
+		for i := range r.buffers {
+			r.buffers[i].entries = make([]*entry, 0, size)
+		}
+		arc.reads = r
+	}
+}
+
+// getBuffered serves a hit under the read lock and records it, it reports false for anything
+// else which needs the write lock: misses, ghosts and expired entries.
+func (a *ARC) getBuffered(key interface{}) (interface{}, bool) {
+	a.mutex.RLock()
+	ent, ok := a.cache[key]
+	if !ok || ent.ghost || ent.expired(time.Now()) {
+		a.mutex.RUnlock()
+		return nil, false
+	}
+	value := ent.value
+	// concurrent readers only, writers hold the lock exclusively
+	atomic.AddUint64(&a.hits, 1)
+	a.mutex.RUnlock()
+
+	a.record(ent)
+	return value, true
+}
+
+// record adds a hit to a read buffer, and applies the full ones if the write lock is free
+func (a *ARC) record(ent *entry) {
+	r := a.reads
+	b := &r.buffers[rand.Intn(len(r.buffers))]
+	b.mutex.Lock()
+	b.entries = append(b.entries, ent)
+	var full []*entry
+	if len(b.entries) >= r.size {
+		full = b.entries
+		b.entries = make([]*entry, 0, r.size)
+	}
+	b.mutex.Unlock()
+	if full == nil {
+		return
+	}
+
+	select {
+	case r.pending <- full:
+	default:
+		// like a sampled access log, dropping hits only makes the recency approximate
+		atomic.AddUint64(&r.dropped, uint64(len(full)))
+	}
+	if a.mutex.TryLock() {
+		a.applyReads()
+		a.mutex.Unlock()
+	}
+}
+
+// applyReads moves the entries of the pending read buffers as Get would have, skipping
+// those which left T1 and T2 since. It must be called with the write lock held.
+func (a *ARC) applyReads() {
+	if a.reads == nil {
+		return
+	}
+	for {
+		select {
+		case batch := <-a.reads.pending:
+			a.applyBatch(batch)
+		default:
+			return
+		}
+	}
+}
+
+// flushReads applies the pending read buffers and the hits held by the buffers not full yet,
+// so that T1 and T2 follow every Get before a key is evicted or the lists are shown. It must
+// be called with the write lock held.
+func (a *ARC) flushReads() {
+	if a.reads == nil {
+		return
+	}
+	a.applyReads()
+	for i := range a.reads.buffers {
+		b := &a.reads.buffers[i]
+		b.mutex.Lock()
+		a.applyBatch(b.entries)
+		b.entries = b.entries[:0]
+		b.mutex.Unlock()
+	}
+}
+
+// syncReads flushes the read buffers for the callers which then only need the read lock
+func (a *ARC) syncReads() {
+	if a.reads == nil {
+		return
+	}
+	a.mutex.Lock()
+	a.flushReads()
+	a.mutex.Unlock()
+}
+
+func (a *ARC) applyBatch(batch []*entry) {
+	for _, ent := range batch {
+		if a.cache[ent.key] != ent || ent.ghost || (ent.ll != a.t1 && ent.ll != a.t2) {
+			continue
+		}
+		a.trace(TraceGet, ent.key)
+		if a.admission != nil {
+			a.admission.increment(ent.key)
+		}
+		for _, s := range a.shadows {
+			s.get(ent.key)
+		}
+		a.access(ent)
+	}
+}
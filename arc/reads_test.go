@@ -0,0 +1,120 @@
+package arc_test
+
+import (
+	"container/list"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+const (
+	benchCapacity = 10000
+	benchKeys     = 100000
+)
+
+// benchmarkGet reads keys following a Zipf distribution from every goroutine, putting the
+// misses back, as the bench command does
+func benchmarkGet(b *testing.B, opts ...arc.Option) {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	c := arc.NewARC(benchCapacity, list.New(), list.New(), list.New(), list.New(), opts...)
+	for i := 0; i < benchCapacity; i++ {
+		c.Put(uint64(i), i)
+	}
+
+	var seed int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+		zipf := rand.NewZipf(r, 1.01, 1, benchKeys-1)
+		for pb.Next() {
+			key := zipf.Uint64()
+			if _, ok := c.Get(key); !ok {
+				c.Put(key, key)
+			}
+		}
+	})
+	b.StopTimer()
+	if dropped := c.Stats().ReadsDropped; dropped > 0 {
+		b.ReportMetric(float64(dropped)/float64(b.N), "dropped/op")
+	}
+}
+
+// benchmarkGetHits reads random keys which are all cached from every goroutine
+func benchmarkGetHits(b *testing.B, opts ...arc.Option) {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	c := arc.NewARC(benchCapacity, list.New(), list.New(), list.New(), list.New(), opts...)
+	for i := 0; i < benchCapacity; i++ {
+		c.Put(i, i)
+	}
+
+	var seed int64
+	var misses uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+		for pb.Next() {
+			if _, ok := c.Get(r.Intn(benchCapacity)); !ok {
+				atomic.AddUint64(&misses, 1)
+			}
+		}
+	})
+	b.StopTimer()
+	if misses > 0 {
+		b.Fatalf("%d misses on cached keys", misses)
+	}
+}
+
+func BenchmarkGetLocked(b *testing.B) {
+	benchmarkGet(b)
+}
+
+func BenchmarkGetBuffered(b *testing.B) {
+	benchmarkGet(b, arc.SetReadBuffer(64))
+}
+
+func BenchmarkGetHitsLocked(b *testing.B) {
+	benchmarkGetHits(b)
+}
+
+func BenchmarkGetHitsBuffered(b *testing.B) {
+	benchmarkGetHits(b, arc.SetReadBuffer(64))
+}
+
+// TestBufferedHits checks that hits served under the read lock reach T2 before they are looked
+// at, although a single hit does not fill its buffer
+func TestBufferedHits(t *testing.T) {
+	c := newCache(2, arc.SetReadBuffer(64))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a is not cached")
+	}
+	if stats := c.Stats(); stats.T1 != 1 || stats.T2 != 1 {
+		t.Fatalf("got T1 %d and T2 %d, want 1 and 1", stats.T1, stats.T2)
+	}
+
+	c = newCache(2, arc.SetReadBuffer(64))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")
+	if s := c.Snapshot(); len(s.T2) != 1 || s.T2[0].Key != "a" {
+		t.Fatalf("got T2 %v, want a", s.T2)
+	}
+
+	c = newCache(2, arc.SetReadBuffer(64))
+	c.Put("a", 1)
+	c.Put("b", 2)
+	c.Get("a")
+	c.Put("c", 3)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a was read, b should have been evicted instead")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b is still cached")
+	}
+}
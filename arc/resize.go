@@ -0,0 +1,68 @@
+package arc
+
+import (
+	"errors"
+
+	"github.com/deepak11627/arc/utils"
+)
+
+var (
+	// ErrBadCapacity is returned by Resize for a capacity lower than 1
+	ErrBadCapacity = errors.New("arc: capacity must be positive")
+	// ErrResizeWAL is returned by Resize on a cache logging to a write-ahead log, whose snapshot
+	// must keep the capacity the cache is opened with
+	ErrResizeWAL = errors.New("arc: cannot resize a cache with a write-ahead log")
+)
+
+// Resize changes the capacity of the cache to c. When it shrinks, entries are moved to the ghost
+// lists by REPLACE until at most c are cached, then the LRU ghosts are forgotten until
+// |T1| + |B1| ≤ c and the four lists hold at most 2c entries. p is capped at c, and pinned entries
+// beyond the pinned capacity of the new size are unpinned, least recently used first.
+func (a *ARC) Resize(c int) error {
+	if c < 1 {
+		return ErrBadCapacity
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.wal != nil {
+		return ErrResizeWAL
+	}
+	a.resize(c)
+	return nil
+}
+
+func (a *ARC) resize(c int) {
+	a.flushReads()
+	a.logger.Debug("Resizing cache", "from", a.c, "to", c)
+	a.c = c
+	a.p = utils.Min(a.p, c)
+	for _, l := range []ListService{a.t1, a.t2} {
+		for e := l.Back(); e != nil && a.pinned > a.maxPinned(); e = e.Prev() {
+			if ent := e.Value.(*entry); ent.pinned {
+				ent.pinned = false
+				a.pinned--
+			}
+		}
+	}
+
+	for a.len > c {
+		a.replace(false)
+	}
+	for a.t1.Len()+a.b1Len() > c && a.b1Len() > 0 {
+		a.delLRU(a.b1, EvictDropB1)
+		a.dbRemove("B1")
+	}
+	for a.t1.Len()+a.t2.Len()+a.b1Len()+a.b2Len() > 2*c && a.b2Len() > 0 {
+		a.delLRU(a.b2, EvictDropB2)
+		a.dbRemove("B2")
+	}
+}
+
+// capacity returns c
+func (a *ARC) capacity() int {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.c
+}
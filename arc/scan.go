@@ -0,0 +1,184 @@
+package arc
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultScanCount is the number of keys Scan returns when count is not positive
+const defaultScanCount = 10
+
+type scanKey struct {
+	fp  uint64
+	key interface{}
+}
+
+// Scan returns about count keys holding a value and the cursor to pass to the next call, 0 once
+// every key was visited, starting from cursor 0. Keys are visited in the order of their hash, so
+// a key cached for the whole scan is returned exactly once whatever is put or deleted meanwhile.
+// Keys sharing a hash are returned together, which may exceed count. Unless it is empty or "*",
+// match only returns the string keys matching the glob pattern, see Redis SCAN. Every call visits
+// the whole cache, so large caches are better scanned with a large count. Recency is not changed.
+func (a *ARC) Scan(cursor uint64, match string, count int) ([]interface{}, uint64) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+	if match == "*" {
+		match = ""
+	}
+
+	a.mutex.RLock()
+	now := time.Now()
+	var found []scanKey
+	for key, ent := range a.cache {
+		if ent.ghost || ent.expired(now) {
+			continue
+		}
+		fp := fingerprint(key)
+		if fp < cursor {
+			continue
+		}
+		if match != "" {
+			if k, ok := key.(string); !ok || !globMatch(match, k) {
+				continue
+			}
+		}
+		found = append(found, scanKey{fp: fp, key: key})
+	}
+	a.mutex.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].fp < found[j].fp })
+	var next uint64
+	n := len(found)
+	if n > count {
+		n = count
+		for n < len(found) && found[n].fp == found[n-1].fp {
+			n++
+		}
+		if n < len(found) {
+			next = found[n].fp
+		}
+	}
+	keys := make([]interface{}, n)
+	for i := range keys {
+		keys[i] = found[i].key
+	}
+	return keys, next
+}
+
+// Range calls fn with the keys holding a value and their values until it returns false, without
+// changing their recency. The keys are listed when Range starts and every value is read when fn
+// reaches its key, so fn may use the cache: keys removed meanwhile are skipped and keys added
+// meanwhile are not visited.
+func (a *ARC) Range(fn func(key, value interface{}) bool) {
+	a.mutex.RLock()
+	now := time.Now()
+	keys := make([]interface{}, 0, a.len)
+	for key, ent := range a.cache {
+		if !ent.ghost && !ent.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	a.mutex.RUnlock()
+
+	for _, key := range keys {
+		if value, ok := a.peek(key); ok && !fn(key, value) {
+			return
+		}
+	}
+}
+
+// peek returns the value of key without recording an access
+func (a *ARC) peek(key interface{}) (interface{}, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	ent, ok := a.live(key)
+	if !ok {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// globMatch reports whether s matches pattern, a glob of Redis: * matches any bytes, ? one byte,
+// [abc] and [a-z] one byte of the set, [^abc] one byte outside it, and \ escapes the next byte
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, next := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				star, next = p, i
+				p++
+				continue
+			}
+			if n, ok := globByte(pattern[p:], s[i]); ok {
+				p += n
+				i++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		// let the last star match one more byte
+		next++
+		p, i = star+1, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// globByte matches c against the element pattern starts with, which is not a star, and returns
+// the length of the element
+func globByte(pattern string, c byte) (int, bool) {
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '\\':
+		if len(pattern) > 1 {
+			return 2, pattern[1] == c
+		}
+	case '[':
+		return globClass(pattern, c)
+	}
+	return 1, pattern[0] == c
+}
+
+// globClass matches c against the set pattern starts with, an unterminated [ is a literal
+func globClass(pattern string, c byte) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!')
+	if negate {
+		i++
+	}
+	in := false
+	for ; i < len(pattern); i++ {
+		if pattern[i] == ']' {
+			return i + 1, in != negate
+		}
+		lo := pattern[i]
+		if lo == '\\' && i+1 < len(pattern) {
+			i++
+			lo = pattern[i]
+		}
+		hi := lo
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			i += 2
+			hi = pattern[i]
+			if hi == '\\' && i+1 < len(pattern) {
+				i++
+				hi = pattern[i]
+			}
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo <= c && c <= hi {
+			in = true
+		}
+	}
+	return 1, c == '['
+}
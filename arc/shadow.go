@@ -0,0 +1,232 @@
+package arc
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/deepak11627/arc/utils"
+)
+
+// Shadow is a key only cache simulation fed with the key stream of a real cache.
+// It never stores values, so it can be used to evaluate other sizes or policies
+// against live traffic at the cost of a few pointers per key.
+type Shadow interface {
+	// Name identifies the simulation in Stats, e.g. "arc-200" or "lru-100"
+	Name() string
+	// Capacity is the number of keys the simulated cache can hold
+	Capacity() int
+	// Get replays a read of key and reports whether it would have been a hit
+	Get(key interface{}) bool
+	// Put replays a write of key
+	Put(key interface{})
+}
+
+// ShadowStats holds the counters of a single shadow simulation
+type ShadowStats struct {
+	Name     string
+	Capacity int
+	Hits     uint64
+	Misses   uint64
+}
+
+// HitRatio returns the fraction of reads the simulated cache would have served
+func (s ShadowStats) HitRatio() float64 {
+	return hitRatio(s.Hits, s.Misses)
+}
+
+// shadow wraps a Shadow with the counters kept on behalf of the ARC
+type shadow struct {
+	Shadow
+	hits   uint64
+	misses uint64
+}
+
+func (s *shadow) get(key interface{}) {
+	if s.Get(key) {
+		s.hits++
+	} else {
+		s.misses++
+	}
+}
+
+func (s *shadow) stats() ShadowStats {
+	return ShadowStats{
+		Name:     s.Name(),
+		Capacity: s.Capacity(),
+		Hits:     s.hits,
+		Misses:   s.misses,
+	}
+}
+
+// SetShadows function to feed the key stream into shadow simulations
+func SetShadows(shadows ...Shadow) func(*ARC) {
+	return func(arc *ARC) {
+		for _, s := range shadows {
+			arc.shadows = append(arc.shadows, &shadow{Shadow: s})
+		}
+	}
+}
+
+// lruShadow simulates a plain LRU cache
+type lruShadow struct {
+	c    int
+	ll   *list.List
+	keys map[interface{}]*list.Element
+}
+
+// NewLRUShadow returns a shadow simulating an LRU cache holding c keys
+func NewLRUShadow(c int) Shadow {
+	return &lruShadow{
+		c:    c,
+		ll:   list.New(),
+		keys: make(map[interface{}]*list.Element, c),
+	}
+}
+
+func (s *lruShadow) Name() string {
+	return fmt.Sprintf("lru-%d", s.c)
+}
+
+func (s *lruShadow) Capacity() int {
+	return s.c
+}
+
+func (s *lruShadow) Get(key interface{}) bool {
+	el, ok := s.keys[key]
+	if ok {
+		s.ll.MoveToFront(el)
+	}
+	return ok
+}
+
+func (s *lruShadow) Put(key interface{}) {
+	if el, ok := s.keys[key]; ok {
+		s.ll.MoveToFront(el)
+		return
+	}
+	if s.c <= 0 {
+		return
+	}
+	if s.ll.Len() >= s.c {
+		lru := s.ll.Back()
+		s.ll.Remove(lru)
+		delete(s.keys, lru.Value)
+	}
+	s.keys[key] = s.ll.PushFront(key)
+}
+
+// arcShadow simulates an ARC of a different size, keeping only keys in all four lists
+type arcShadow struct {
+	c, p           int
+	t1, t2, b1, b2 *list.List
+	keys           map[interface{}]*list.Element
+	lists          map[*list.Element]*list.List
+}
+
+// NewARCShadow returns a shadow simulating an ARC holding c keys
+func NewARCShadow(c int) Shadow {
+	return &arcShadow{
+		c:     c,
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		keys:  make(map[interface{}]*list.Element, 2*c),
+		lists: make(map[*list.Element]*list.List, 2*c),
+	}
+}
+
+func (s *arcShadow) Name() string {
+	return fmt.Sprintf("arc-%d", s.c)
+}
+
+func (s *arcShadow) Capacity() int {
+	return s.c
+}
+
+func (s *arcShadow) Get(key interface{}) bool {
+	el, ok := s.keys[key]
+	if !ok {
+		return false
+	}
+	ll := s.lists[el]
+	if ll != s.t1 && ll != s.t2 {
+		return false
+	}
+	s.move(el, s.t2)
+	return true
+}
+
+func (s *arcShadow) Put(key interface{}) {
+	if s.c <= 0 {
+		return
+	}
+	el, ok := s.keys[key]
+	if !ok {
+		// Case IV
+		if s.t1.Len()+s.b1.Len() == s.c {
+			if s.t1.Len() < s.c {
+				s.drop(s.b1)
+				s.replace(false)
+			} else {
+				s.drop(s.t1)
+			}
+		} else if total := s.t1.Len() + s.t2.Len() + s.b1.Len() + s.b2.Len(); total >= s.c {
+			if total == 2*s.c {
+				s.drop(s.b2)
+			}
+			s.replace(false)
+		}
+		el = s.t1.PushFront(key)
+		s.keys[key] = el
+		s.lists[el] = s.t1
+		return
+	}
+
+	switch s.lists[el] {
+	case s.b1:
+		// Case II
+		d := 1
+		if s.b2.Len() > s.b1.Len() {
+			d = s.b2.Len() / s.b1.Len()
+		}
+		s.p = utils.Min(s.p+d, s.c)
+		s.replace(false)
+	case s.b2:
+		// Case III
+		d := 1
+		if s.b1.Len() > s.b2.Len() {
+			d = s.b1.Len() / s.b2.Len()
+		}
+		s.p = utils.Max(s.p-d, 0)
+		s.replace(true)
+	}
+	// Case I, and the tail of Case II and III
+	s.move(el, s.t2)
+}
+
+func (s *arcShadow) replace(inB2 bool) {
+	if s.t1.Len() > 0 && (s.t1.Len() > s.p || (inB2 && s.t1.Len() == s.p)) {
+		s.move(s.t1.Back(), s.b1)
+	} else if s.t2.Len() > 0 {
+		s.move(s.t2.Back(), s.b2)
+	}
+}
+
+func (s *arcShadow) drop(ll *list.List) {
+	lru := ll.Back()
+	if lru == nil {
+		return
+	}
+	ll.Remove(lru)
+	delete(s.lists, lru)
+	delete(s.keys, lru.Value)
+}
+
+func (s *arcShadow) move(el *list.Element, to *list.List) {
+	key := s.lists[el].Remove(el)
+	delete(s.lists, el)
+	el = to.PushFront(key)
+	s.keys[key] = el
+	s.lists[el] = to
+}
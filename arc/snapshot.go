@@ -0,0 +1,49 @@
+package arc
+
+// Snapshot is a point in time copy of the cache lists, each ordered from MRU to LRU
+type Snapshot struct {
+	C  int             `json:"c"`
+	P  int             `json:"p"`
+	T1 []SnapshotEntry `json:"t1"`
+	T2 []SnapshotEntry `json:"t2"`
+	B1 []SnapshotEntry `json:"b1"`
+	B2 []SnapshotEntry `json:"b2"`
+}
+
+// SnapshotEntry is a single key value pair of a Snapshot, ghosts have a nil value
+type SnapshotEntry struct {
+	Key    interface{} `json:"key"`
+	Value  interface{} `json:"value"`
+	Pinned bool        `json:"pinned,omitempty"`
+}
+
+// Snapshot returns the content of T1, T2, B1 and B2 as shown by Traverse.
+// It does not affect the position of any entry. Compact ghost lists show their fingerprints
+// as keys.
+func (a *ARC) Snapshot() Snapshot {
+	a.syncReads()
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	s := Snapshot{
+		C:  a.c,
+		P:  a.p,
+		T1: snapshotList(a.t1),
+		T2: snapshotList(a.t2),
+		B1: snapshotList(a.b1),
+		B2: snapshotList(a.b2),
+	}
+	if a.g1 != nil {
+		s.B1, s.B2 = snapshotFingerprints(a.g1), snapshotFingerprints(a.g2)
+	}
+	return s
+}
+
+func snapshotList(l ListService) []SnapshotEntry {
+	entries := make([]SnapshotEntry, 0, l.Len())
+	for e := l.Front(); e != nil; e = e.Next() {
+		ent := e.Value.(*entry)
+		entries = append(entries, SnapshotEntry{Key: ent.key, Value: ent.value, Pinned: ent.pinned})
+	}
+	return entries
+}
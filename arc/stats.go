@@ -0,0 +1,76 @@
+package arc
+
+import "time"
+
+// EvictionReason tells why an entry left T1, T2, B1 or B2
+type EvictionReason string
+
+const (
+	// EvictDemoteT1 is a REPLACE moving the LRU entry of T1 to B1
+	EvictDemoteT1 EvictionReason = "t1_to_b1"
+	// EvictDemoteT2 is a REPLACE moving the LRU entry of T2 to B2
+	EvictDemoteT2 EvictionReason = "t2_to_b2"
+	// EvictDropT1 is Case IV removing the LRU entry of T1 from the cache when T1 holds c entries
+	EvictDropT1 EvictionReason = "t1_drop"
+	// EvictDropB1 is Case IV forgetting the LRU ghost of B1
+	EvictDropB1 EvictionReason = "b1_drop"
+	// EvictDropB2 is Case IV forgetting the LRU ghost of B2
+	EvictDropB2 EvictionReason = "b2_drop"
+	// EvictExpired is an entry removed because its time to live passed
+	EvictExpired EvictionReason = "expired"
+)
+
+// Stats holds the shape of a cache and the counters collected since it was created
+type Stats struct {
+	// C is the capacity of the cache and P the current target size of T1
+	C int
+	P int
+	// T1, T2, B1 and B2 are the number of entries in each list
+	T1 int
+	T2 int
+	B1 int
+	B2 int
+	// Hits is the number of Get calls served from T1 or T2
+	Hits uint64
+	// Misses is the number of Get calls for keys absent from the cache or only present as ghosts
+	Misses uint64
+	// GhostHitsB1 and GhostHitsB2 count the accesses to a key remembered in B1 (Case II) or B2 (Case III)
+	GhostHitsB1 uint64
+	GhostHitsB2 uint64
+	// Evictions counts the entries which left a list, by reason
+	Evictions map[EvictionReason]uint64
+	// DBWrites, DBErrors and DBWriteTime describe the writes of ghost entries to the database list service
+	DBWrites    uint64
+	DBErrors    uint64
+	DBWriteTime time.Duration
+	// Pinned is the number of entries kept from eviction by Pin
+	Pinned int
+	// Rejected counts the new keys the admission policy kept out of the cache
+	Rejected uint64
+	// Loads counts the calls of the loader by GetOrLoad, LoadErrors those which failed, and
+	// StaleHits the expired values served while they were reloaded
+	Loads      uint64
+	LoadErrors uint64
+	StaleHits  uint64
+	// StoreSaves and StoreErrors count the values saved to the store set with SetStore and the
+	// saves and deletes which failed, Dirty is the number of values write-back has not saved yet
+	StoreSaves  uint64
+	StoreErrors uint64
+	Dirty       int
+	// ReadsDropped counts the hits served with a read buffer which were dropped before moving their entry
+	ReadsDropped uint64
+	// Shadows holds the counters of every shadow simulation fed by the cache
+	Shadows []ShadowStats
+}
+
+// HitRatio returns the fraction of Get calls that were served from the cache
+func (s Stats) HitRatio() float64 {
+	return hitRatio(s.Hits, s.Misses)
+}
+
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,304 @@
+package arc
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFlushInterval is how often write-back saves the dirty values unless SetFlushInterval is used
+const defaultFlushInterval = time.Second
+
+// Store is the backing store of a cache, such as a database table. Load returns ErrNotFound for
+// the keys it does not hold.
+type Store interface {
+	Load(key interface{}) (interface{}, error)
+	Save(key, value interface{}) error
+	Delete(key interface{}) error
+}
+
+// WriteMode tells when the values put in a cache are saved to its store
+type WriteMode int
+
+const (
+	// WriteThrough saves every value before caching it, a value which fails to save is not cached
+	WriteThrough WriteMode = iota + 1
+	// WriteBack caches values as dirty and saves them in the background soon after they leave
+	// T1 or T2, every flush interval, on Flush and on CloseStore. Deletes are written back too.
+	WriteBack
+)
+
+// StoreError holds the error of every key the store failed to save. PutMany returns it for
+// write-through, and Flush and Close for write-back, keeping the values dirty for the next flush.
+type StoreError struct {
+	Errors map[interface{}]error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("arc: the store failed to save %d keys", len(e.Errors))
+}
+
+// store is the backing store of a cache and, with write-back, the values it has not saved yet
+type store struct {
+	// saves and errors come first to be 64-bit aligned for atomic access
+	saves  uint64
+	errors uint64
+	Store
+	mode WriteMode
+	// dirty holds the values waiting to be saved, whether they are still cached or not, and the
+	// keys waiting to be deleted. A flush only clears the values it saved which were not
+	// written again meanwhile.
+	dirty map[interface{}]*dirtyValue
+	// evicted holds the dirty keys which left T1 or T2 since the last flush
+	evicted map[interface{}]struct{}
+	// flushes serializes the flushes, which save without the lock of the cache
+	flushes sync.Mutex
+
+	wake  chan struct{}
+	done  chan struct{}
+	close sync.Once
+	wg    sync.WaitGroup
+}
+
+// dirtyValue is a value write-back has not saved yet, or a key it has not deleted yet
+type dirtyValue struct {
+	value   interface{}
+	deleted bool
+}
+
+// SetStore function to back the cache with s: Delete removes keys from it, GetOrLoad loads the
+// keys it misses from it unless SetLoader is used, and the values put are saved to it as mode tells.
+func SetStore(s Store, mode WriteMode) func(*ARC) {
+	return func(arc *ARC) {
+		arc.store = &store{
+			Store:   s,
+			mode:    mode,
+			dirty:   make(map[interface{}]*dirtyValue),
+			evicted: make(map[interface{}]struct{}),
+			wake:    make(chan struct{}, 1),
+			done:    make(chan struct{}),
+		}
+	}
+}
+
+// SetFlushInterval function to set how often write-back saves the dirty values, every second
+// by default. Periodic flushes are disabled when d is 0 or less.
+func SetFlushInterval(d time.Duration) func(*ARC) {
+	return func(arc *ARC) {
+		arc.flushInterval = d
+	}
+}
+
+// SetStoreErrorHandler function to be told of every value the store fails to save or delete,
+// instead of logging them. It may be called with the cache locked and must not use the cache.
+func SetStoreErrorHandler(fn func(key interface{}, err error)) func(*ARC) {
+	return func(arc *ARC) {
+		arc.storeErrors = fn
+	}
+}
+
+// startStore starts the flusher of write-back, once the options are set
+func (a *ARC) startStore() {
+	if a.store == nil || a.store.mode != WriteBack {
+		return
+	}
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if a.flushInterval > 0 {
+		ticker = time.NewTicker(a.flushInterval)
+		tick = ticker.C
+	}
+	a.store.wg.Add(1)
+	go func() {
+		defer a.store.wg.Done()
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+
+		for {
+			select {
+			case <-tick:
+				a.flushStore(true)
+			case <-a.store.wake:
+				a.flushStore(false)
+			case <-a.store.done:
+				return
+			}
+		}
+	}()
+}
+
+// CloseStore stops the flusher of write-back and saves the dirty values, returning a *StoreError
+// for those which failed. Values put later are saved on Flush only.
+func (a *ARC) CloseStore() error {
+	if a.store == nil {
+		return nil
+	}
+	a.store.close.Do(func() { close(a.store.done) })
+	a.store.wg.Wait()
+
+	return a.flushStore(true)
+}
+
+// write logs and puts a value, saving it first with write-through or marking it dirty with
+// write-back. It reports whether the key held a value, or the error of a value which failed to
+// encode, log or save and was not cached.
+func (a *ARC) write(key, value interface{}, expires time.Time) (bool, error) {
+	record, err := a.walRecord(walPut, key, value, expires, "")
+	if err != nil {
+		return false, err
+	}
+	if err := a.storeValue(key, value); err != nil {
+		return false, err
+	}
+	if err := a.walWrite(record); err != nil {
+		return false, err
+	}
+	return a.put(key, value, expires), nil
+}
+
+// storeValue saves a value with write-through or marks it dirty with write-back. Values the
+// admission policy keeps out of the cache go through it too, so that no write is lost.
+// Write-through saves with the lock held, so that the store sees the writes of a key in the
+// order the cache does, at the cost of readers waiting for the store.
+func (a *ARC) storeValue(key, value interface{}) error {
+	if a.store == nil {
+		return nil
+	}
+	switch a.store.mode {
+	case WriteThrough:
+		return a.saveStore(key, value)
+	case WriteBack:
+		a.store.dirty[key] = &dirtyValue{value: value}
+	}
+	return nil
+}
+
+// writeBack wakes the flusher up to save the dirty value of a key leaving T1 or T2
+func (a *ARC) writeBack(key interface{}) {
+	if a.store == nil {
+		return
+	}
+	if _, ok := a.store.dirty[key]; !ok {
+		return
+	}
+	a.store.evicted[key] = struct{}{}
+	select {
+	case a.store.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flushStore saves every dirty value when all is set, or those which left T1 or T2 otherwise.
+// The values are taken under the lock and saved without it.
+func (a *ARC) flushStore(all bool) error {
+	if a.store == nil {
+		return nil
+	}
+	a.store.flushes.Lock()
+	defer a.store.flushes.Unlock()
+
+	a.mutex.Lock()
+	var dirty map[interface{}]*dirtyValue
+	if all {
+		dirty = make(map[interface{}]*dirtyValue, len(a.store.dirty))
+		for key, d := range a.store.dirty {
+			dirty[key] = d
+		}
+	} else {
+		dirty = make(map[interface{}]*dirtyValue, len(a.store.evicted))
+		for key := range a.store.evicted {
+			if d, ok := a.store.dirty[key]; ok {
+				dirty[key] = d
+			}
+		}
+	}
+	a.store.evicted = make(map[interface{}]struct{})
+	a.mutex.Unlock()
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	a.logger.Debug("Flushing dirty items to the store", "count", len(dirty))
+	var errs map[interface{}]error
+	for key, d := range dirty {
+		var err error
+		if d.deleted {
+			err = a.deleteStore(key)
+		} else {
+			err = a.saveStore(key, d.value)
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make(map[interface{}]error)
+			}
+			errs[key] = err
+			delete(dirty, key)
+		}
+	}
+
+	a.mutex.Lock()
+	for key, d := range dirty {
+		if a.store.dirty[key] == d {
+			delete(a.store.dirty, key)
+		}
+	}
+	a.mutex.Unlock()
+	if errs != nil {
+		return &StoreError{Errors: errs}
+	}
+	return nil
+}
+
+func (a *ARC) saveStore(key, value interface{}) error {
+	atomic.AddUint64(&a.store.saves, 1)
+	err := a.store.Save(key, value)
+	if err != nil {
+		a.storeFailed(key, err)
+	}
+	return err
+}
+
+func (a *ARC) deleteStore(key interface{}) error {
+	err := a.store.Delete(key)
+	if err != nil {
+		a.storeFailed(key, err)
+	}
+	return err
+}
+
+// unstore deletes a key from the store, when the next flush does with write-back. Write-through
+// deletes with the lock held, like it saves.
+func (a *ARC) unstore(key interface{}) {
+	if a.store == nil {
+		return
+	}
+	if a.store.mode == WriteBack {
+		a.store.dirty[key] = &dirtyValue{deleted: true}
+		return
+	}
+	a.deleteStore(key)
+}
+
+// stored returns the value of key write-back has not saved yet, ok is false when there is none
+// and err is ErrNotFound when the key waits to be deleted
+func (a *ARC) stored(key interface{}) (value interface{}, ok bool, err error) {
+	d, ok := a.store.dirty[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if d.deleted {
+		return nil, true, ErrNotFound
+	}
+	return d.value, true, nil
+}
+
+func (a *ARC) storeFailed(key interface{}, err error) {
+	atomic.AddUint64(&a.store.errors, 1)
+	if a.storeErrors != nil {
+		a.storeErrors(key, err)
+		return
+	}
+	a.logger.Error("Store failed", "item_key", fmt.Sprintf("%s", key), "err", err)
+}
@@ -0,0 +1,150 @@
+package arc_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+var errUnavailable = errors.New("store unavailable")
+
+// memStore keeps the values in a map and fails every save and delete while failing is set
+type memStore struct {
+	mutex   sync.Mutex
+	values  map[interface{}]interface{}
+	failing bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{values: make(map[interface{}]interface{})}
+}
+
+func (s *memStore) Load(key interface{}) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.values[key]
+	if !ok {
+		return nil, arc.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *memStore) Save(key, value interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failing {
+		return errUnavailable
+	}
+	s.values[key] = value
+	return nil
+}
+
+func (s *memStore) Delete(key interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failing {
+		return errUnavailable
+	}
+	delete(s.values, key)
+	return nil
+}
+
+func (s *memStore) fail(failing bool) {
+	s.mutex.Lock()
+	s.failing = failing
+	s.mutex.Unlock()
+}
+
+// has reports whether the store holds value at key
+func (s *memStore) has(key, value interface{}) bool {
+	v, err := s.Load(key)
+	return err == nil && v == value
+}
+
+// newStoreCache returns a cache backed by store, its store closed at the end of the test
+func newStoreCache(t *testing.T, c int, store arc.Store, mode arc.WriteMode) *arc.ARC {
+	cache := newCache(c, arc.SetStore(store, mode), arc.SetFlushInterval(0),
+		arc.SetStoreErrorHandler(func(interface{}, error) {}))
+	t.Cleanup(func() { cache.CloseStore() })
+	return cache
+}
+
+func TestWriteThrough(t *testing.T) {
+	store := newMemStore()
+	cache := newStoreCache(t, 10, store, arc.WriteThrough)
+	cache.Put("a", 1)
+	if !store.has("a", 1) {
+		t.Fatal("a was not saved by the put")
+	}
+	cache.Delete("a")
+	if _, err := store.Load("a"); err != arc.ErrNotFound {
+		t.Fatal("a was not deleted from the store")
+	}
+
+	store.fail(true)
+	if _, err := cache.TryPut("b", 2, 0); err != errUnavailable {
+		t.Fatalf("got %v, want %v", err, errUnavailable)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("a value which failed to save was cached")
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	store := newMemStore()
+	cache := newStoreCache(t, 10, store, arc.WriteBack)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	if store.has("a", 1) {
+		t.Fatal("a was saved before the flush")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+	if !store.has("a", 1) || !store.has("b", 2) {
+		t.Fatal("the flush did not save a and b")
+	}
+
+	cache.Delete("a")
+	cache.Put("b", 3)
+	store.fail(true)
+	err := cache.Flush()
+	if serr, ok := err.(*arc.StoreError); !ok || len(serr.Errors) != 2 {
+		t.Fatalf("got %v, want a *arc.StoreError for a and b", err)
+	}
+	if !store.has("a", 1) || !store.has("b", 2) {
+		t.Fatal("the failed flush changed the store")
+	}
+
+	store.fail(false)
+	if err := cache.CloseStore(); err != nil {
+		t.Fatalf("closing the store: %v", err)
+	}
+	if store.has("a", 1) || !store.has("b", 3) {
+		t.Fatal("closing the store did not write back the values kept dirty")
+	}
+}
+
+func TestWriteBackEviction(t *testing.T) {
+	store := newMemStore()
+	cache := newStoreCache(t, 1, store, arc.WriteBack)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !store.has("a", 1) {
+		if time.Now().After(deadline) {
+			t.Fatal("a was not saved once evicted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if store.has("b", 2) {
+		t.Fatal("b was saved while still cached")
+	}
+}
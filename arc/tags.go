@@ -0,0 +1,173 @@
+package arc
+
+import (
+	"strings"
+	"time"
+)
+
+// SetKeyIndex function to keep the string keys of the cache sorted, so that InvalidatePrefix only
+// visits the matching keys rather than the whole cache. It costs a string per key.
+func SetKeyIndex(on bool) func(*ARC) {
+	return func(arc *ARC) {
+		arc.keys = nil
+		if on {
+			arc.keys = newKeyIndex()
+		}
+	}
+}
+
+// SetInvalidateGhosts function to also forget the ghosts of the keys matched by InvalidateTag and
+// InvalidatePrefix, so that putting them back counts as a new key rather than a ghost hit.
+// Compact ghost lists only keep fingerprints, their ghosts are never matched.
+func SetInvalidateGhosts(on bool) func(*ARC) {
+	return func(arc *ARC) {
+		arc.invalidateGhosts = on
+	}
+}
+
+// PutWithTags inserts a key-value pair like PutWithTTL, a ttl of zero or less meaning it never
+// expires, and replaces the tags of the key with tags for InvalidateTag. Put keeps the tags
+// of a key, which stay with its ghost until it leaves B1 or B2.
+func (a *ARC) PutWithTags(key, value interface{}, ttl time.Duration, tags ...string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.trace(TracePut, key)
+	if !a.admits(key) {
+		a.reject(key)
+		a.storeValue(key, value)
+		return false
+	}
+	expires := expiry(ttl)
+	ok, err := a.write(key, value, expires)
+	if err != nil {
+		return false
+	}
+	a.walAppend(walTags, key, tags, time.Time{}, "")
+	a.tag(key, tags)
+	return ok
+}
+
+// Tags returns the tags of key
+func (a *ARC) Tags(key interface{}) []string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	ent, ok := a.live(key)
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), ent.tags...)
+}
+
+// InvalidateTag removes the keys tagged with tag from T1 and T2, and from B1 and B2 with
+// SetInvalidateGhosts. It returns the number of values removed.
+func (a *ARC) InvalidateTag(tag string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	keys := make([]interface{}, 0, len(a.tags[tag]))
+	for key := range a.tags[tag] {
+		keys = append(keys, key)
+	}
+	return a.invalidate(keys, "tag "+tag)
+}
+
+// InvalidatePrefix removes the string keys starting with prefix like InvalidateTag. Without
+// SetKeyIndex every key of the cache is compared to the prefix.
+func (a *ARC) InvalidatePrefix(prefix string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var keys []interface{}
+	if a.keys != nil {
+		a.keys.ascend(prefix, func(k string) bool {
+			if !strings.HasPrefix(k, prefix) {
+				return false
+			}
+			keys = append(keys, k)
+			return true
+		})
+	} else {
+		for key := range a.cache {
+			if k, ok := key.(string); ok && strings.HasPrefix(k, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return a.invalidate(keys, "prefix "+prefix)
+}
+
+// invalidate removes keys as Delete does, skipping ghosts unless they are invalidated too
+func (a *ARC) invalidate(keys []interface{}, match string) int {
+	a.applyReads()
+	n := 0
+	for _, key := range keys {
+		ent, ok := a.cache[key]
+		if !ok || (ent.ghost && !a.invalidateGhosts) {
+			continue
+		}
+		a.walAppend(walDelete, key, nil, time.Time{}, "")
+		from := a.listName(ent.ll)
+		a.remove(ent)
+		a.dropped(ent, from, "invalidate")
+		if !ent.ghost {
+			n++
+		}
+	}
+	a.logger.Debug("Invalidated items", "match", match, "count", n)
+	return n
+}
+
+// tag replaces the tags of the entry of key
+func (a *ARC) tag(key interface{}, tags []string) {
+	ent, ok := a.cache[key]
+	if !ok {
+		return
+	}
+	a.untag(ent)
+	ent.tags = nil
+	for _, t := range tags {
+		if _, dup := a.tags[t][key]; dup {
+			continue
+		}
+		if a.tags[t] == nil {
+			a.tags[t] = make(map[interface{}]struct{})
+		}
+		a.tags[t][key] = struct{}{}
+		ent.tags = append(ent.tags, t)
+	}
+}
+
+func (a *ARC) untag(ent *entry) {
+	for _, t := range ent.tags {
+		delete(a.tags[t], ent.key)
+		if len(a.tags[t]) == 0 {
+			delete(a.tags, t)
+		}
+	}
+}
+
+// indexed adds an entry entering the cache to the key index
+func (a *ARC) indexed(ent *entry) {
+	if k, ok := ent.key.(string); ok && a.keys != nil {
+		a.keys.insert(k)
+	}
+}
+
+// unindexed removes an entry leaving the cache from the key and tag indexes
+func (a *ARC) unindexed(ent *entry) {
+	if k, ok := ent.key.(string); ok && a.keys != nil {
+		a.keys.remove(k)
+	}
+	a.untag(ent)
+	ent.tags = nil
+}
+
+// resetIndexes empties the key and tag indexes
+func (a *ARC) resetIndexes() {
+	if a.keys != nil {
+		a.keys.reset()
+	}
+	a.tags = make(map[string]map[interface{}]struct{})
+}
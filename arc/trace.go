@@ -0,0 +1,20 @@
+package arc
+
+// Operations recorded by a TraceService
+const (
+	TraceGet = "get"
+	TracePut = "put"
+)
+
+// SetTracer function to record every Get and Put key of the cache
+func SetTracer(t TraceService) func(*ARC) {
+	return func(arc *ARC) {
+		arc.tracer = t
+	}
+}
+
+func (a *ARC) trace(op string, key interface{}) {
+	if a.tracer != nil {
+		a.tracer.Record(op, key)
+	}
+}
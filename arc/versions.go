@@ -0,0 +1,90 @@
+package arc
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by CompareAndSwap for a key which is not cached
+	ErrNotFound = errors.New("arc: key not found")
+	// ErrVersionMismatch is returned by CompareAndSwap when the value changed since it was read
+	ErrVersionMismatch = errors.New("arc: version mismatch")
+)
+
+func (a *ARC) nextVersion() uint64 {
+	a.version++
+	return a.version
+}
+
+// live returns the entry of key when it holds a value which has not expired
+func (a *ARC) live(key interface{}) (*entry, bool) {
+	ent, ok := a.cache[key]
+	if !ok || ent.ghost || ent.expired(time.Now()) {
+		return nil, false
+	}
+	return ent, true
+}
+
+// GetWithVersion is Get also returning the version of the value, to give to CompareAndSwap.
+// Versions are never zero.
+func (a *ARC) GetWithVersion(key interface{}) (interface{}, uint64, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.trace(TraceGet, key)
+	for _, s := range a.shadows {
+		s.get(key)
+	}
+	value, ok := a.get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	return value, a.cache[key].version, true
+}
+
+// Version returns the version of the value cached at key without affecting its position
+func (a *ARC) Version(key interface{}) (uint64, bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	ent, ok := a.live(key)
+	if !ok {
+		return 0, false
+	}
+	return ent.version, true
+}
+
+// CompareAndSwap stores value at key only if the cached value still has the given version,
+// keeping its expiry, and returns the new version. It counts as a Put for the position of the key.
+func (a *ARC) CompareAndSwap(key interface{}, version uint64, value interface{}) (uint64, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	ent, ok := a.live(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if ent.version != version {
+		return 0, ErrVersionMismatch
+	}
+	a.trace(TracePut, key)
+	if _, err := a.write(key, value, ent.expires); err != nil {
+		return 0, err
+	}
+	return ent.version, nil
+}
+
+// PutIfAbsent stores value at key unless the key already holds a value, ghosts do not count.
+// It reports whether the value was stored.
+func (a *ARC) PutIfAbsent(key, value interface{}) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.live(key); ok {
+		return false
+	}
+	a.trace(TracePut, key)
+	_, err := a.write(key, value, time.Time{})
+	return err == nil
+}
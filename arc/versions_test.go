@@ -0,0 +1,65 @@
+package arc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	cache := newCache(10)
+	cache.PutWithTTL("k", "a", time.Hour)
+	value, version, ok := cache.GetWithVersion("k")
+	if !ok || value != "a" || version == 0 {
+		t.Fatalf("got %v, %d, %v, want a with a version", value, version, ok)
+	}
+
+	if _, err := cache.CompareAndSwap("k", version+1, "b"); err != arc.ErrVersionMismatch {
+		t.Fatalf("stale version: got %v, want %v", err, arc.ErrVersionMismatch)
+	}
+	if _, err := cache.CompareAndSwap("missing", version, "b"); err != arc.ErrNotFound {
+		t.Fatalf("missing key: got %v, want %v", err, arc.ErrNotFound)
+	}
+	swapped, err := cache.CompareAndSwap("k", version, "b")
+	if err != nil || swapped == version {
+		t.Fatalf("got version %d, %v, want a new version", swapped, err)
+	}
+	if v, ok := cache.Version("k"); !ok || v != swapped {
+		t.Fatalf("got version %d, %v, want %d", v, ok, swapped)
+	}
+	if ttl, ok := cache.TTL("k"); !ok || ttl <= 59*time.Minute {
+		t.Fatalf("got a ttl of %v, want the one kept from the put", ttl)
+	}
+	if _, err := cache.CompareAndSwap("k", version, "c"); err != arc.ErrVersionMismatch {
+		t.Fatalf("swapping twice: got %v, want %v", err, arc.ErrVersionMismatch)
+	}
+
+	cache.Put("k", "d")
+	if v, _ := cache.Version("k"); v == swapped {
+		t.Fatal("a put kept the version")
+	}
+	if value, _ := cache.Get("k"); value != "d" {
+		t.Fatalf("got %v, want d", value)
+	}
+}
+
+func TestPutIfAbsent(t *testing.T) {
+	cache := newCache(1)
+	if !cache.PutIfAbsent("a", 1) {
+		t.Fatal("a was not stored")
+	}
+	if cache.PutIfAbsent("a", 2) {
+		t.Fatal("a was stored over a value")
+	}
+	cache.Put("b", 3)
+	if _, ok := cache.Version("a"); ok {
+		t.Fatal("a should be a ghost")
+	}
+	if !cache.PutIfAbsent("a", 4) {
+		t.Fatal("a was not stored over its ghost")
+	}
+	if value, _ := cache.Get("a"); value != 4 {
+		t.Fatalf("got %v, want 4", value)
+	}
+}
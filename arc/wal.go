@@ -0,0 +1,517 @@
+package arc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy tells when the write-ahead log is synced to disk. Records are always handed
+// to the operating system as they are written, so they survive the process crashing,
+// the policy decides how much is lost when the machine does.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs after every record
+	SyncAlways SyncPolicy = iota
+	// SyncInterval syncs every WALConfig.SyncInterval
+	SyncInterval
+	// SyncNever leaves syncing to the operating system
+	SyncNever
+)
+
+// WALConfig configures the write-ahead log opened by OpenWAL
+type WALConfig struct {
+	// Dir holds the snapshot and the log, it is created if needed
+	Dir  string
+	Sync SyncPolicy
+	// SyncInterval is the time between syncs with SyncInterval, a second by default
+	SyncInterval time.Duration
+	// CompactSize is the size of the log past which it is compacted into the snapshot,
+	// 64 MiB by default
+	CompactSize int64
+	// CompactInterval compacts the log periodically as well when set
+	CompactInterval time.Duration
+}
+
+// Files of the write-ahead log directory
+const (
+	walSnapshotFile = "snapshot.arc"
+	walLogFile      = "wal.log"
+	walMagic        = "ARCW"
+)
+
+// Operations recorded in the write-ahead log
+const (
+	walPut byte = iota + 1
+	walGet
+	walDelete
+	walTouch
+	walPurge
+	walEvict
+	walPin
+	walUnpin
+	walTags
+)
+
+// ErrWALOpen is returned by OpenWAL on a cache already logging to a write-ahead log
+var ErrWALOpen = errors.New("arc: write-ahead log already open")
+
+// wal is an open write-ahead log. Records are appended with the cache locked,
+// its own mutex orders them with the background syncs.
+type wal struct {
+	cfg WALConfig
+
+	mutex      sync.Mutex
+	f          *os.File
+	size       int64
+	generation uint64
+	dirty      bool
+
+	// compactions asks the background goroutine to compact the log
+	compactions chan struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// OpenWAL recovers the cache from the write-ahead log in cfg.Dir, loading the snapshot
+// and replaying the log on top of it, then records every change of the cache to the log.
+// It is meant to be called on a new cache, before it is used, and the cache must be closed
+// with Close. Statistics are reset once recovered.
+//
+// The log holds Puts, Gets of ghosts, Touches, Deletes, Purges, pins and tags, so that replaying
+// them rebuilds T1, T2, B1, B2 and p. Hits are not logged, so the rebuild is approximate: the
+// recency of the keys read since the last compaction is lost. Evictions are recorded too and
+// checked during the replay. A record torn by a crash ends the log, it is cut off.
+func (a *ARC) OpenWAL(cfg WALConfig) error {
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = time.Second
+	}
+	if cfg.CompactSize <= 0 {
+		cfg.CompactSize = 64 << 20
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.wal != nil {
+		return ErrWALOpen
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return err
+	}
+	w := &wal{cfg: cfg, compactions: make(chan struct{}, 1), done: make(chan struct{})}
+
+	// the snapshot starts with the generation of the log written after it
+	if f, err := os.Open(filepath.Join(cfg.Dir, walSnapshotFile)); err == nil {
+		r := bufio.NewReader(f)
+		w.generation, err = binary.ReadUvarint(r)
+		var s *saved
+		if err == nil {
+			s, err = a.decode(r)
+		}
+		if err == nil {
+			err = a.restore(s)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("arc: unable to load the write-ahead log snapshot: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(cfg.Dir, walLogFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	// the log only holds the puts which were admitted
+	admission := a.admission
+	a.admission = nil
+	err = a.replayWAL(w)
+	a.admission = admission
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	a.hits, a.misses = 0, 0
+	a.ghostHitsB1, a.ghostHitsB2 = 0, 0
+	a.evictions = make(map[EvictionReason]uint64)
+
+	a.wal = w
+	w.wg.Add(1)
+	go a.walLoop(w)
+	return nil
+}
+
+// replayWAL applies the records of the log of w, and leaves it ready to append to
+func (a *ARC) replayWAL(w *wal) error {
+	r := bufio.NewReader(w.f)
+	header := make([]byte, len(walMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(walMagic)]) != walMagic ||
+		binary.BigEndian.Uint64(header[len(walMagic):]) != w.generation {
+		// empty, torn while being created, or left over from before the last compaction
+		return w.reset()
+	}
+
+	offset := int64(len(header))
+	mismatches := 0
+	for {
+		payload, n, err := readWALRecord(r)
+		if err != nil {
+			if err != io.EOF {
+				a.logger.Warn("Cutting the write-ahead log at a torn record", "offset", offset, "err", err)
+			}
+			break
+		}
+		if !a.applyWALRecord(payload) {
+			mismatches++
+		}
+		offset += n
+	}
+	if mismatches > 0 {
+		// hits are not logged, so the keys read since the last compaction may be evicted in their place
+		a.logger.Info("Evictions replayed from the write-ahead log differ from the ones recorded", "mismatches", mismatches)
+	}
+
+	if err := w.f.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	w.size = offset
+	return nil
+}
+
+// readWALRecord reads a record framed as its length as a uvarint, the payload and its CRC-32
+func readWALRecord(r *bufio.Reader) ([]byte, int64, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length > 2*maxPersistField {
+		return nil, 0, ErrBadSnapshot
+	}
+	b := make([]byte, length+4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := b[:length]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(b[length:]) {
+		return nil, 0, ErrBadSnapshot
+	}
+	var buf [binary.MaxVarintLen64]byte
+	return payload, int64(binary.PutUvarint(buf[:], length)) + int64(len(b)), nil
+}
+
+// applyWALRecord replays a record, it reports false when an eviction did not happen again
+func (a *ARC) applyWALRecord(payload []byte) bool {
+	pr := &persistReader{r: bufio.NewReader(bytes.NewReader(payload)), crc: crc32.NewIEEE()}
+	op, _ := pr.ReadByte()
+	switch op {
+	case walPut:
+		key, value := pr.field(a.codec), pr.field(a.codec)
+		expires := walTime(pr.varint())
+		if pr.err == nil {
+			a.put(key, value, expires)
+		}
+	case walGet:
+		if key := pr.field(a.codec); pr.err == nil {
+			a.get(key)
+		}
+	case walDelete:
+		if key := pr.field(a.codec); pr.err == nil {
+			a.delete(key)
+		}
+	case walTouch:
+		key := pr.field(a.codec)
+		expires := walTime(pr.varint())
+		if ent, ok := a.cache[key]; pr.err == nil && ok && !ent.ghost {
+			ent.expires = expires
+		}
+	case walPurge:
+		a.purge()
+	case walPin:
+		if key := pr.field(a.codec); pr.err == nil {
+			a.pin(key)
+		}
+	case walUnpin:
+		if key := pr.field(a.codec); pr.err == nil {
+			a.unpin(key)
+		}
+	case walTags:
+		key := pr.field(a.codec)
+		n := pr.uvarint()
+		var tags []string
+		for i := uint64(0); i < n && pr.err == nil; i++ {
+			tags = append(tags, pr.str())
+		}
+		if pr.err == nil {
+			a.tag(key, tags)
+		}
+	case walEvict:
+		key := pr.field(a.codec)
+		reason, _ := pr.field(a.codec).(string)
+		if pr.err != nil {
+			return true
+		}
+		ent, ok := a.cache[key]
+		if r := EvictionReason(reason); r == EvictDemoteT1 || r == EvictDemoteT2 {
+			if a.g1 != nil {
+				return a.ghostList(key) != nil
+			}
+			return ok && ent.ghost
+		}
+		return !ok
+	}
+	return true
+}
+
+func walTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// walAppend records an operation, it does nothing without a write-ahead log. The error of a
+// record which could not be encoded or written is logged and returned, callers which can refuse
+// the operation do so.
+func (a *ARC) walAppend(op byte, key, value interface{}, expires time.Time, reason EvictionReason) error {
+	payload, err := a.walRecord(op, key, value, expires, reason)
+	if err != nil {
+		return err
+	}
+	return a.walWrite(payload)
+}
+
+// walRecord encodes an operation, it returns nil without a write-ahead log
+func (a *ARC) walRecord(op byte, key, value interface{}, expires time.Time, reason EvictionReason) ([]byte, error) {
+	if a.wal == nil {
+		return nil, nil
+	}
+	var payload bytes.Buffer
+	pw := &persistWriter{w: &payload}
+	pw.write([]byte{op})
+	switch op {
+	case walPut:
+		pw.field(a.codec.Encode(key))
+		pw.field(a.codec.Encode(value))
+		pw.varint(walNanos(expires))
+	case walGet, walDelete, walPin, walUnpin:
+		pw.field(a.codec.Encode(key))
+	case walTouch:
+		pw.field(a.codec.Encode(key))
+		pw.varint(walNanos(expires))
+	case walEvict:
+		pw.field(a.codec.Encode(key))
+		pw.field(a.codec.Encode(string(reason)))
+	case walTags:
+		pw.field(a.codec.Encode(key))
+		tags, _ := value.([]string)
+		pw.uvarint(uint64(len(tags)))
+		for _, t := range tags {
+			pw.str(t)
+		}
+	}
+	if pw.err != nil {
+		a.logger.Error("Unable to encode write-ahead log record", "err", pw.err)
+		return nil, pw.err
+	}
+	return payload.Bytes(), nil
+}
+
+// walWrite appends a record encoded by walRecord, it does nothing for a nil record
+func (a *ARC) walWrite(payload []byte) error {
+	if payload == nil {
+		return nil
+	}
+	if err := a.wal.append(payload); err != nil {
+		a.logger.Error("Unable to write to the write-ahead log", "err", err)
+		return err
+	}
+	if a.wal.size >= a.wal.cfg.CompactSize {
+		// the operation being recorded is not applied yet, compact once it is
+		select {
+		case a.wal.compactions <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func walNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (w *wal) append(payload []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	b := make([]byte, binary.MaxVarintLen64+len(payload)+4)
+	n := binary.PutUvarint(b, uint64(len(payload)))
+	n += copy(b[n:], payload)
+	binary.BigEndian.PutUint32(b[n:], crc32.ChecksumIEEE(payload))
+	n, err := w.f.Write(b[:n+4])
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if w.cfg.Sync == SyncAlways {
+		return w.f.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// reset empties the log and starts it over for the current generation
+func (w *wal) reset() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, len(walMagic)+8)
+	copy(header, walMagic)
+	binary.BigEndian.PutUint64(header[len(walMagic):], w.generation)
+	if _, err := w.f.Write(header); err != nil {
+		return err
+	}
+	w.size = int64(len(header))
+	w.dirty = false
+	return w.f.Sync()
+}
+
+func (w *wal) sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.f.Sync()
+}
+
+// Compact saves the cache as the snapshot of the write-ahead log and empties the log
+func (a *ARC) Compact() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.wal == nil {
+		return nil
+	}
+	return a.compact()
+}
+
+// compact writes the snapshot under a new generation before emptying the log, so that a crash
+// in between leaves a log recovery knows to ignore rather than one replayed twice
+func (a *ARC) compact() error {
+	w := a.wal
+	path := filepath.Join(w.cfg.Dir, walSnapshotFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	_, err = f.Write(buf[:binary.PutUvarint(buf[:], w.generation+1)])
+	if err == nil {
+		err = a.save(f)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if d, err := os.Open(w.cfg.Dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	w.generation++
+	return w.reset()
+}
+
+func (a *ARC) walLoop(w *wal) {
+	defer w.wg.Done()
+
+	var syncs, compactions <-chan time.Time
+	if w.cfg.Sync == SyncInterval {
+		t := time.NewTicker(w.cfg.SyncInterval)
+		defer t.Stop()
+		syncs = t.C
+	}
+	if w.cfg.CompactInterval > 0 {
+		t := time.NewTicker(w.cfg.CompactInterval)
+		defer t.Stop()
+		compactions = t.C
+	}
+	compact := func() {
+		if err := a.Compact(); err != nil {
+			a.logger.Error("Unable to compact the write-ahead log", "err", err)
+		}
+	}
+	for {
+		select {
+		case <-syncs:
+			if err := w.sync(); err != nil {
+				a.logger.Error("Unable to sync the write-ahead log", "err", err)
+			}
+		case <-compactions:
+			compact()
+		case <-w.compactions:
+			compact()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close syncs and closes the write-ahead log, later changes are no longer recorded
+func (a *ARC) Close() error {
+	a.mutex.Lock()
+	w := a.wal
+	a.wal = nil
+	a.mutex.Unlock()
+
+	if w == nil {
+		return nil
+	}
+	close(w.done)
+	w.wg.Wait()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
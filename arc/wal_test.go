@@ -0,0 +1,108 @@
+package arc_test
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// unregistered is a value type the gob codec cannot encode
+type unregistered struct {
+	N int
+}
+
+func newCache(c int, opts ...arc.Option) *arc.ARC {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	return arc.NewARC(c, list.New(), list.New(), list.New(), list.New(), opts...).(*arc.ARC)
+}
+
+// openWAL opens a write-ahead log in dir on a new cache, closed at the end of the test
+func openWAL(t *testing.T, dir string, c int, opts ...arc.Option) *arc.ARC {
+	t.Helper()
+	cache := newCache(c, opts...)
+	if err := cache.OpenWAL(arc.WALConfig{Dir: dir, Sync: arc.SyncNever}); err != nil {
+		t.Fatalf("opening the write-ahead log: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// logSize returns the size of the log of the write-ahead log in dir
+func logSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	fi, err := os.Stat(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatalf("reading the size of the log: %v", err)
+	}
+	return fi.Size()
+}
+
+func TestWALRefusesValuesItCannotEncode(t *testing.T) {
+	dir := t.TempDir()
+	cache := openWAL(t, dir, 10)
+	if cache.Put("k", unregistered{1}) {
+		t.Fatal("Put reported an existing key")
+	}
+	if _, ok := cache.Get("k"); ok {
+		t.Fatal("a value missing from the log was cached")
+	}
+	if _, err := cache.TryPut("k", unregistered{1}, 0); err == nil {
+		t.Fatal("TryPut returned no error for a value missing from the log")
+	}
+	if cache.PutWithTags("k", unregistered{1}, 0, "t") {
+		t.Fatal("PutWithTags cached a value missing from the log")
+	}
+	if cache.Len() != 0 {
+		t.Fatalf("got %d cached keys, want 0", cache.Len())
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	cache := openWAL(t, dir, 2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("a", 3)
+	cache.Put("c", 4)
+	cache.Put("d", 5)
+	cache.Delete("d")
+	want := cache.Snapshot()
+	if err := cache.Close(); err != nil {
+		t.Fatalf("closing the write-ahead log: %v", err)
+	}
+
+	if got := openWAL(t, dir, 2).Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed %+v, want %+v", got, want)
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	dir := t.TempDir()
+	cache := openWAL(t, dir, 4)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	before := logSize(t, dir)
+	if err := cache.Compact(); err != nil {
+		t.Fatalf("compacting: %v", err)
+	}
+	if after := logSize(t, dir); after >= before {
+		t.Fatalf("the log went from %d to %d bytes, want it emptied", before, after)
+	}
+	cache.Put("c", 3)
+	cache.Delete("a")
+	want := cache.Snapshot()
+	cache.Close()
+
+	reopened := openWAL(t, dir, 4)
+	if got := reopened.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("recovered %+v, want %+v", got, want)
+	}
+	if v, ok := reopened.Get("b"); !ok || v != 2 {
+		t.Fatalf("got b = %v, %v, want 2 from the snapshot", v, ok)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"container/list"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+	"github.com/deepak11627/arc/utils"
+)
+
+// Bench measures the throughput of concurrent Gets on a cache served under the write lock and
+// on one with a read buffer, with keys following a Zipf distribution and misses Put back
+func Bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	goroutines := fs.Int("goroutines", runtime.GOMAXPROCS(0), "Number of goroutines reading the cache.")
+	duration := fs.Duration("duration", 3*time.Second, "How long every cache is read for.")
+	keys := fs.Int("keys", 100000, "Number of distinct keys read.")
+	skew := fs.Float64("s", 1.01, "Skew of the Zipf distribution of the keys, greater than 1.")
+	buffer := fs.Int("buffer", 64, "Size of the read buffers of the buffered cache.")
+	fs.Parse(args)
+
+	size := CacheSize
+	if size <= 0 {
+		size = 10000
+	}
+	utils.RenderMessageHeading(fmt.Sprintf("%d goroutines reading %d keys for %s, cache of %d keys.", *goroutines, *keys, *duration, size))
+	for _, b := range []struct {
+		name string
+		opts []arc.Option
+	}{
+		{"write lock", nil},
+		{fmt.Sprintf("read buffer %d", *buffer), []arc.Option{arc.SetReadBuffer(*buffer)}},
+	} {
+		opts := append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, b.opts...)
+		c := arc.NewARC(size, list.New(), list.New(), list.New(), list.New(), opts...)
+		ops := bench(c, *goroutines, *duration, *keys, *skew)
+		stats := c.Stats()
+		fmt.Printf("\n%s: %.0f ops/s, hit ratio %.2f", b.name, float64(ops)/duration.Seconds(), stats.HitRatio())
+		if stats.ReadsDropped > 0 {
+			fmt.Printf(", %d hits dropped", stats.ReadsDropped)
+		}
+	}
+	fmt.Println()
+	utils.RenderMessageEnd()
+	return nil
+}
+
+// bench reads c from every goroutine until duration passed and returns the number of Gets
+func bench(c arc.CacheService, goroutines int, duration time.Duration, keys int, skew float64) uint64 {
+	var ops uint64
+	var stop int32
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			zipf := rand.NewZipf(rand.New(rand.NewSource(seed)), skew, 1, uint64(keys-1))
+			var n uint64
+			for atomic.LoadInt32(&stop) == 0 {
+				key := zipf.Uint64()
+				if _, ok := c.Get(key); !ok {
+					c.Put(key, key)
+				}
+				n++
+			}
+			atomic.AddUint64(&ops, n)
+		}(int64(i))
+	}
+	time.Sleep(duration)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+	return ops
+}
@@ -0,0 +1,467 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/utils"
+)
+
+// Values travel as strings: Put accepts strings, byte slices and anything fmt can print,
+// and Get returns the stored value as a string. Keys are printed with fmt as well.
+// Network and server errors are logged and reported as misses, use Do to see them.
+
+// Get retrieves the value of key from the server
+func (c *Client) Get(key interface{}) (interface{}, bool) {
+	reply, err := c.Do("GET", key)
+	if err != nil {
+		c.logger.Error("GET failed", "key", fmt.Sprint(key), "err", err)
+		return nil, false
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false
+	}
+	return string(b), true
+}
+
+// Put stores value at key, it reports whether the key already held a value
+func (c *Client) Put(key, value interface{}) bool {
+	return c.PutWithTTL(key, value, 0)
+}
+
+// PutWithTTL stores value at key for ttl, a ttl of zero or less never expires
+func (c *Client) PutWithTTL(key, value interface{}, ttl time.Duration) bool {
+	// EXISTS does not count as a read on the server, unlike SET with GET
+	p := c.Pipeline()
+	p.Do("EXISTS", key)
+	p.PutWithTTL(key, value, ttl)
+	results, err := p.Exec()
+	if err == nil {
+		err = results[1].Err
+	}
+	if err != nil {
+		c.logger.Error("SET failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	return results[0].Value == int64(1)
+}
+
+// Touch sets the time to live of key, a ttl of zero or less removes its expiry
+func (c *Client) Touch(key interface{}, ttl time.Duration) bool {
+	if ttl <= 0 {
+		// PERSIST only reports keys which had an expiry, so check the key exists first
+		if _, ok := c.TTL(key); !ok {
+			return false
+		}
+		if _, err := c.Do("PERSIST", key); err != nil {
+			c.logger.Error("PERSIST failed", "key", fmt.Sprint(key), "err", err)
+			return false
+		}
+		return true
+	}
+	reply, err := c.Do("PEXPIRE", key, int64(ttl/time.Millisecond))
+	if err != nil {
+		c.logger.Error("PEXPIRE failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	return reply == int64(1)
+}
+
+// TTL returns the time left before key expires, zero if it never does
+func (c *Client) TTL(key interface{}) (time.Duration, bool) {
+	reply, err := c.Do("PTTL", key)
+	if err != nil {
+		c.logger.Error("PTTL failed", "key", fmt.Sprint(key), "err", err)
+		return 0, false
+	}
+	ms, _ := reply.(int64)
+	switch {
+	case ms == -2:
+		return 0, false
+	case ms < 0:
+		return 0, true
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// Delete removes key, it reports whether the key held a value
+func (c *Client) Delete(key interface{}) bool {
+	reply, err := c.Do("DEL", key)
+	if err != nil {
+		c.logger.Error("DEL failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	return reply == int64(1)
+}
+
+// GetMany retrieves the values of keys with a single MGET, missing keys are absent from the result
+func (c *Client) GetMany(keys []interface{}) map[interface{}]interface{} {
+	values := make(map[interface{}]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values
+	}
+	reply, err := c.Do(append([]interface{}{"MGET"}, keys...)...)
+	if err != nil {
+		c.logger.Error("MGET failed", "keys", len(keys), "err", err)
+		return values
+	}
+	a, _ := reply.([]interface{})
+	for i, v := range a {
+		if b, ok := v.([]byte); ok && i < len(keys) {
+			values[keys[i]] = string(b)
+		}
+	}
+	return values
+}
+
+// PutMany stores the values of entries in a single pipeline, it reports by key whether the
+// key already held a value and returns an *arc.StoreError for the keys which failed to store
+func (c *Client) PutMany(entries map[interface{}]interface{}) (map[interface{}]bool, error) {
+	existed := make(map[interface{}]bool, len(entries))
+	if len(entries) == 0 {
+		return existed, nil
+	}
+	keys := make([]interface{}, 0, len(entries))
+	p := c.Pipeline()
+	for key, value := range entries {
+		keys = append(keys, key)
+		p.Do("EXISTS", key)
+		p.Put(key, value)
+	}
+	results, err := p.Exec()
+	if err != nil {
+		c.logger.Error("SET failed", "keys", len(keys), "err", err)
+		return existed, err
+	}
+	var errs map[interface{}]error
+	for i, key := range keys {
+		if err := results[2*i+1].Err; err != nil {
+			c.logger.Error("SET failed", "key", fmt.Sprint(key), "err", err)
+			if errs == nil {
+				errs = make(map[interface{}]error)
+			}
+			errs[key] = err
+			continue
+		}
+		existed[key] = results[2*i].Value == int64(1)
+	}
+	if errs != nil {
+		return existed, &arc.StoreError{Errors: errs}
+	}
+	return existed, nil
+}
+
+// GetWithVersion retrieves the value of key along with its version
+func (c *Client) GetWithVersion(key interface{}) (interface{}, uint64, bool) {
+	reply, err := c.Do("ARC.GETS", key)
+	if err != nil {
+		c.logger.Error("ARC.GETS failed", "key", fmt.Sprint(key), "err", err)
+		return nil, 0, false
+	}
+	a, ok := reply.([]interface{})
+	if !ok || len(a) != 2 {
+		return nil, 0, false
+	}
+	b, _ := a[0].([]byte)
+	version, _ := a[1].(int64)
+	return string(b), uint64(version), true
+}
+
+// Version returns the version of the value of key
+func (c *Client) Version(key interface{}) (uint64, bool) {
+	reply, err := c.Do("ARC.VERSION", key)
+	if err != nil {
+		c.logger.Error("ARC.VERSION failed", "key", fmt.Sprint(key), "err", err)
+		return 0, false
+	}
+	version, ok := reply.(int64)
+	return uint64(version), ok
+}
+
+// CompareAndSwap stores value at key if its version did not change, and returns the new version.
+// Network and server errors are returned as is.
+func (c *Client) CompareAndSwap(key interface{}, version uint64, value interface{}) (uint64, error) {
+	reply, err := c.Do("ARC.CAS", key, version, value)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return 0, arc.ErrNotFound
+	case int64:
+		if v == 0 {
+			return 0, arc.ErrVersionMismatch
+		}
+		return uint64(v), nil
+	}
+	return 0, errUnexpectedReply
+}
+
+// PutIfAbsent stores value at key unless the key holds a value, it reports whether it was stored
+func (c *Client) PutIfAbsent(key, value interface{}) bool {
+	reply, err := c.Do("SET", key, value, "NX")
+	if err != nil {
+		c.logger.Error("SET NX failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	return reply == "OK"
+}
+
+// Increment adds delta to the integer at key and returns the result, a missing key counts as 0.
+// Network and server errors are returned as is.
+func (c *Client) Increment(key interface{}, delta int64) (int64, error) {
+	return c.count("INCRBY", key, delta)
+}
+
+// Decrement subtracts delta from the integer at key and returns the result
+func (c *Client) Decrement(key interface{}, delta int64) (int64, error) {
+	return c.count("DECRBY", key, delta)
+}
+
+func (c *Client) count(cmd string, key interface{}, delta int64) (int64, error) {
+	reply, err := c.Do(cmd, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errUnexpectedReply
+	}
+	return n, nil
+}
+
+// IncrementFloat adds delta to the number at key and returns the result
+func (c *Client) IncrementFloat(key interface{}, delta float64) (float64, error) {
+	reply, err := c.Do("INCRBYFLOAT", key, strconv.FormatFloat(delta, 'f', -1, 64))
+	if err != nil {
+		return 0, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return 0, errUnexpectedReply
+	}
+	return strconv.ParseFloat(string(b), 64)
+}
+
+// DecrementFloat subtracts delta from the number at key and returns the result
+func (c *Client) DecrementFloat(key interface{}, delta float64) (float64, error) {
+	return c.IncrementFloat(key, -delta)
+}
+
+// Pin keeps the value of key from being evicted by the server.
+// Network and server errors are returned as is.
+func (c *Client) Pin(key interface{}) error {
+	reply, err := c.Do("ARC.PIN", key)
+	if err != nil {
+		if e, ok := err.(Error); ok && string(e) == "ERR pinned capacity reached" {
+			return arc.ErrPinLimit
+		}
+		return err
+	}
+	if reply == nil {
+		return arc.ErrNotFound
+	}
+	return nil
+}
+
+// Unpin lets the server evict the value of key again, it reports whether it was pinned
+func (c *Client) Unpin(key interface{}) bool {
+	reply, err := c.Do("ARC.UNPIN", key)
+	if err != nil {
+		c.logger.Error("ARC.UNPIN failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	n, _ := reply.(int64)
+	return n == 1
+}
+
+// PutWithTags stores value at key for ttl, a ttl of zero or less never expires, with tags for
+// InvalidateTag. It reports whether the key already held a value.
+func (c *Client) PutWithTags(key, value interface{}, ttl time.Duration, tags ...string) bool {
+	var ms int64
+	if ttl > 0 {
+		ms = int64(ttl / time.Millisecond)
+		if ms == 0 {
+			ms = 1
+		}
+	}
+	args := []interface{}{"ARC.SETTAGS", key, value, ms}
+	for _, t := range tags {
+		args = append(args, t)
+	}
+	reply, err := c.Do(args...)
+	if err != nil {
+		c.logger.Error("ARC.SETTAGS failed", "key", fmt.Sprint(key), "err", err)
+		return false
+	}
+	return reply == int64(1)
+}
+
+// InvalidateTag removes the keys tagged with tag and returns how many held a value
+func (c *Client) InvalidateTag(tag string) int {
+	return c.invalidate("ARC.INVALIDATETAG", tag)
+}
+
+// InvalidatePrefix removes the keys starting with prefix and returns how many held a value
+func (c *Client) InvalidatePrefix(prefix string) int {
+	return c.invalidate("ARC.INVALIDATEPREFIX", prefix)
+}
+
+func (c *Client) invalidate(cmd, match string) int {
+	reply, err := c.Do(cmd, match)
+	if err != nil {
+		c.logger.Error(cmd+" failed", "match", match, "err", err)
+		return 0
+	}
+	n, _ := reply.(int64)
+	return int(n)
+}
+
+// Scan returns a page of the keys matching the glob match, about count long, and the cursor of
+// the next page, 0 after the last one. The first page is at cursor 0.
+func (c *Client) Scan(cursor uint64, match string, count int) ([]interface{}, uint64) {
+	args := []interface{}{"SCAN", strconv.FormatUint(cursor, 10)}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	reply, err := c.Do(args...)
+	if err != nil {
+		c.logger.Error("SCAN failed", "cursor", cursor, "err", err)
+		return nil, 0
+	}
+	a, _ := reply.([]interface{})
+	if len(a) != 2 {
+		return nil, 0
+	}
+	b, _ := a[0].([]byte)
+	next, _ := strconv.ParseUint(string(b), 10, 64)
+	page, _ := a[1].([]interface{})
+	keys := make([]interface{}, 0, len(page))
+	for _, k := range page {
+		if b, ok := k.([]byte); ok {
+			keys = append(keys, string(b))
+		}
+	}
+	return keys, next
+}
+
+// Purge empties the remote cache
+func (c *Client) Purge() {
+	if _, err := c.Do("FLUSHDB"); err != nil {
+		c.logger.Error("FLUSHDB failed", "err", err)
+	}
+}
+
+// Len returns the number of keys cached by the server
+func (c *Client) Len() int {
+	reply, err := c.Do("DBSIZE")
+	if err != nil {
+		c.logger.Error("DBSIZE failed", "err", err)
+		return 0
+	}
+	n, _ := reply.(int64)
+	return int(n)
+}
+
+// Stats returns the statistics reported by the server's INFO command
+func (c *Client) Stats() arc.Stats {
+	var stats arc.Stats
+	reply, err := c.Do("INFO")
+	if err != nil {
+		c.logger.Error("INFO failed", "err", err)
+		return stats
+	}
+	info, _ := reply.([]byte)
+	for _, line := range strings.Split(string(info), "\r\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "arc_c":
+			stats.C, _ = strconv.Atoi(parts[1])
+		case "arc_p":
+			stats.P, _ = strconv.Atoi(parts[1])
+		case "arc_t1":
+			stats.T1, _ = strconv.Atoi(parts[1])
+		case "arc_t2":
+			stats.T2, _ = strconv.Atoi(parts[1])
+		case "arc_b1":
+			stats.B1, _ = strconv.Atoi(parts[1])
+		case "arc_b2":
+			stats.B2, _ = strconv.Atoi(parts[1])
+		case "keyspace_hits":
+			stats.Hits, _ = strconv.ParseUint(parts[1], 10, 64)
+		case "keyspace_misses":
+			stats.Misses, _ = strconv.ParseUint(parts[1], 10, 64)
+		case "arc_pinned":
+			stats.Pinned, _ = strconv.Atoi(parts[1])
+		case "arc_rejected":
+			stats.Rejected, _ = strconv.ParseUint(parts[1], 10, 64)
+		case "arc_shadow":
+			stats.Shadows = append(stats.Shadows, parseShadow(parts[1]))
+		}
+	}
+	return stats
+}
+
+// parseShadow reads a shadow line of INFO like "name=lru-100,capacity=100,hits=3,misses=1"
+func parseShadow(val string) arc.ShadowStats {
+	var s arc.ShadowStats
+	for _, field := range strings.Split(val, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "name":
+			s.Name = kv[1]
+		case "capacity":
+			s.Capacity, _ = strconv.Atoi(kv[1])
+		case "hits":
+			s.Hits, _ = strconv.ParseUint(kv[1], 10, 64)
+		case "misses":
+			s.Misses, _ = strconv.ParseUint(kv[1], 10, 64)
+		}
+	}
+	return s
+}
+
+// Snapshot returns the content of the server's T1, T2, B1 and B2 lists
+func (c *Client) Snapshot() arc.Snapshot {
+	var snap arc.Snapshot
+	reply, err := c.Do("ARC.SNAPSHOT")
+	if err != nil {
+		c.logger.Error("ARC.SNAPSHOT failed", "err", err)
+		return snap
+	}
+	b, _ := reply.([]byte)
+	if err := json.Unmarshal(b, &snap); err != nil {
+		c.logger.Error("Unable to decode snapshot", "err", err)
+	}
+	return snap
+}
+
+// Traverse prints the items of the remote lists
+func (c *Client) Traverse() {
+	snap := c.Snapshot()
+	utils.RenderMessageHeading("Items are cached.")
+	for _, l := range []struct {
+		name    string
+		entries []arc.SnapshotEntry
+	}{{"T1", snap.T1}, {"T2", snap.T2}, {"B1", snap.B1}, {"B2", snap.B2}} {
+		fmt.Printf("\n%s items are\n", l.name)
+		for _, e := range l.entries {
+			fmt.Printf("%s -> %s\n", e.Key, e.Value)
+		}
+	}
+	utils.RenderMessageEnd()
+}
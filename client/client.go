@@ -0,0 +1,253 @@
+// Package client is a Go client for the cache served over the Redis protocol.
+// A Client implements arc.CacheService, so code can swap an in-process ARC for a remote one.
+package client
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+var (
+	_ arc.CacheService   = (*Client)(nil)
+	_ arc.ExpiryService  = (*Client)(nil)
+	_ arc.VersionService = (*Client)(nil)
+	_ arc.CounterService = (*Client)(nil)
+	_ arc.BatchService   = (*Client)(nil)
+	_ arc.PinService     = (*Client)(nil)
+	_ arc.TagService     = (*Client)(nil)
+)
+
+// ErrClosed is returned by commands issued after Close
+var ErrClosed = errors.New("client: closed")
+
+// idempotent lists the commands which can be sent again after the server may have run them
+var idempotent = map[string]bool{
+	"GET":          true,
+	"MGET":         true,
+	"EXISTS":       true,
+	"TTL":          true,
+	"PTTL":         true,
+	"SCAN":         true,
+	"DBSIZE":       true,
+	"INFO":         true,
+	"PING":         true,
+	"ARC.GETS":     true,
+	"ARC.VERSION":  true,
+	"ARC.SNAPSHOT": true,
+}
+
+// Client keeps a pool of connections to a cache server
+type Client struct {
+	addr         string
+	logger       arc.Logger
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	retries      int
+
+	mutex  sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// Option type setting params dynamically
+type Option func(*Client)
+
+// SetLogger function to set logger dynamically
+func SetLogger(l arc.Logger) func(*Client) {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// SetPoolSize sets the number of idle connections kept open, 8 by default
+func SetPoolSize(n int) func(*Client) {
+	return func(c *Client) {
+		c.idle = make([]*conn, 0, n)
+	}
+}
+
+// SetTimeouts sets the timeouts for dialing, and for reading and writing a command
+func SetTimeouts(dial, read, write time.Duration) func(*Client) {
+	return func(c *Client) {
+		c.dialTimeout = dial
+		c.readTimeout = read
+		c.writeTimeout = write
+	}
+}
+
+// SetRetries sets how many times a command is sent again on a fresh connection
+// after a network error, 1 by default. Commands which change the cache are only sent
+// again when the connection failed before any of them was written.
+func SetRetries(n int) func(*Client) {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// NewClient returns a client for the cache server listening on addr.
+// Connections are opened lazily, on the first command.
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{
+		addr:         addr,
+		logger:       log.NewNopLogger(),
+		dialTimeout:  5 * time.Second,
+		readTimeout:  3 * time.Second,
+		writeTimeout: 3 * time.Second,
+		retries:      1,
+		idle:         make([]*conn, 0, 8),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Close closes the idle connections, connections in use are closed when released
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// Do sends a single command and returns its reply. Replies are decoded as
+// string, []byte, int64, nil, []interface{} or an Error sent by the server.
+func (c *Client) Do(args ...interface{}) (interface{}, error) {
+	replies, err := c.do([][]interface{}{args})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// do writes all commands on one connection before reading their replies, retrying
+// on a new connection when the network fails. Once a byte was written the server may
+// have run the commands, so they are only retried when they are all idempotent.
+func (c *Client) do(cmds [][]interface{}) ([]interface{}, error) {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		var cn *conn
+		if cn, err = c.get(); err != nil {
+			continue
+		}
+		var replies []interface{}
+		replies, err = cn.roundTrip(cmds, c.readTimeout, c.writeTimeout)
+		if err != nil {
+			cn.Close()
+			if cn.written > 0 && !retriable(cmds) {
+				c.logger.Warn("Command failed", "addr", c.addr, "err", err)
+				return nil, err
+			}
+			c.logger.Warn("Command failed, reconnecting", "addr", c.addr, "err", err)
+			continue
+		}
+		c.put(cn)
+		return replies, nil
+	}
+	return nil, err
+}
+
+func (c *Client) get() (*conn, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mutex.Unlock()
+		return cn, nil
+	}
+	c.mutex.Unlock()
+
+	nc, err := net.DialTimeout("tcp", c.addr, c.dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	cn.w = bufio.NewWriter(cn)
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed || len(c.idle) == cap(c.idle) {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// retriable reports whether all cmds are idempotent
+func retriable(cmds [][]interface{}) bool {
+	for _, args := range cmds {
+		name, _ := args[0].(string)
+		if !idempotent[strings.ToUpper(name)] {
+			return false
+		}
+	}
+	return true
+}
+
+// conn is a single connection of the pool
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+	// written counts the bytes of the current round trip written to the connection
+	written int
+}
+
+// Write counts the bytes written to the connection
+func (cn *conn) Write(p []byte) (int, error) {
+	n, err := cn.Conn.Write(p)
+	cn.written += n
+	return n, err
+}
+
+func (cn *conn) roundTrip(cmds [][]interface{}, readTimeout, writeTimeout time.Duration) ([]interface{}, error) {
+	cn.written = 0
+	if writeTimeout > 0 {
+		cn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	for _, args := range cmds {
+		if err := writeCommand(cn.w, args); err != nil {
+			return nil, err
+		}
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	if readTimeout > 0 {
+		cn.SetReadDeadline(time.Now().Add(readTimeout))
+	}
+	replies := make([]interface{}, len(cmds))
+	for i := range cmds {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
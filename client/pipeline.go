@@ -0,0 +1,83 @@
+package client
+
+import (
+	"time"
+)
+
+// Pipeline queues commands and sends them together on a single connection,
+// saving a round trip per command for batch operations.
+type Pipeline struct {
+	c    *Client
+	cmds [][]interface{}
+}
+
+// Result is the reply to one command of a pipeline
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// Pipeline returns an empty pipeline
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Do queues a raw command
+func (p *Pipeline) Do(args ...interface{}) {
+	p.cmds = append(p.cmds, args)
+}
+
+// Get queues a read of key, its Result holds a []byte or nil when missing
+func (p *Pipeline) Get(key interface{}) {
+	p.Do("GET", key)
+}
+
+// Put queues a write of value at key
+func (p *Pipeline) Put(key, value interface{}) {
+	p.Do("SET", key, value)
+}
+
+// PutWithTTL queues a write of value at key which expires after ttl
+func (p *Pipeline) PutWithTTL(key, value interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		p.Put(key, value)
+		return
+	}
+	p.Do("SET", key, value, "PX", int64(ttl/time.Millisecond))
+}
+
+// Delete queues the removal of key
+func (p *Pipeline) Delete(key interface{}) {
+	p.Do("DEL", key)
+}
+
+// Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends the queued commands and returns their replies in order.
+// The error is only set when the commands could not be sent, errors replied
+// by the server for a single command are in its Result. The pipeline is
+// empty afterwards and can be reused.
+func (p *Pipeline) Exec() ([]Result, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	replies, err := p.c.do(cmds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(replies))
+	for i, r := range replies {
+		if e, ok := r.(Error); ok {
+			results[i].Err = e
+			continue
+		}
+		results[i].Value = r
+	}
+	return results, nil
+}
@@ -10,6 +10,11 @@ type NopLogger struct {
 	*zap.Logger
 }
 
+// DebugEnabled reports that debug messages are dropped
+func (l *NopLogger) DebugEnabled() bool {
+	return false
+}
+
 // Debug logs a debug message to the zap logger
 func (l *NopLogger) Debug(msg string, keyvals ...interface{}) {
 	//	l.Debug(msg, keyvals...)
@@ -30,6 +30,7 @@ const (
 // Logger used for loggin purpose through out the application
 type Logger struct {
 	*zap.SugaredLogger
+	level zap.AtomicLevel
 }
 
 // NewLogger return a logger
@@ -70,11 +71,17 @@ func NewLogger(config *Config) (arc.Logger, error) {
 	}
 
 	return &Logger{
-		logger.Sugar(),
+		SugaredLogger: logger.Sugar(),
+		level:         cfg.Level,
 	}, nil
 
 }
 
+// DebugEnabled reports whether debug messages are logged
+func (l *Logger) DebugEnabled() bool {
+	return l.level.Enabled(zap.DebugLevel)
+}
+
 // Debug logs a debug message to the zap logger
 func (l *Logger) Debug(msg string, keyvals ...interface{}) {
 	l.Debugw(msg, keyvals...)
@@ -0,0 +1,144 @@
+// Package logreplay rebuilds the operations of a cache and the content of its lists
+// from the JSON debug log the cache writes, out.log, to explain incidents after the fact.
+package logreplay
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the layout of the time field written by the logger
+const TimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// Operations recovered from the log
+const (
+	OpPut    = "put"
+	OpGet    = "get"
+	OpDelete = "delete"
+	OpExpire = "expire"
+	OpPurge  = "purge"
+)
+
+// Operation is a call on the cache recovered from the log, with what the log says the cache did
+type Operation struct {
+	Time  time.Time
+	Op    string
+	Key   string
+	Value string
+	// Case is the case of req logged for the operation, 0 when none was
+	Case int
+	// Demoted are the keys the log shows moving from T1 to B1 or T2 to B2 during the operation,
+	// prefixed with the list they left, e.g. "t1:q"
+	Demoted []string
+}
+
+// line is a log line, only the fields used are decoded
+type line struct {
+	Time    string `json:"time"`
+	Msg     string `json:"msg"`
+	Item    string `json:"item"`
+	ItemKey string `json:"item_key"`
+	// ItemValue is logged with ItemKey for the entries logged whole in Item
+	ItemValue string `json:"item_value"`
+	// ItemKeu is the misspelled key field of reads
+	ItemKeu string `json:"item_keu"`
+}
+
+// Parse reads the operations from a log, lines which are not JSON or not about the cache are skipped.
+// Logs written before item_key and item_value were logged with the entries have their keys and
+// values recovered from the printed entries, where keys containing " value:" are misread.
+func Parse(r io.Reader) ([]Operation, error) {
+	var ops []Operation
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		var l line
+		if err := json.Unmarshal(s.Bytes(), &l); err != nil {
+			continue
+		}
+		t, err := time.Parse(TimeLayout, l.Time)
+		if err != nil {
+			continue
+		}
+
+		var last *Operation
+		if len(ops) > 0 {
+			last = &ops[len(ops)-1]
+		}
+		switch {
+		case l.Msg == "Adding a new entry item to cache.":
+			key, value := l.entry()
+			ops = append(ops, Operation{Time: t, Op: OpPut, Key: key, Value: value})
+		case l.Msg == "Item found in cache, will adjust its position":
+			ops = append(ops, Operation{Time: t, Op: OpPut, Key: l.ItemKey})
+		case l.Msg == "Reading a value from cache, will adjust its position":
+			ops = append(ops, Operation{Time: t, Op: OpGet, Key: l.ItemKeu})
+		case l.Msg == "Item expired, removing it from cache":
+			ops = append(ops, Operation{Time: t, Op: OpExpire, Key: l.ItemKey})
+		case l.Msg == "Deleting item from cache":
+			key, _ := l.entry()
+			ops = append(ops, Operation{Time: t, Op: OpDelete, Key: key})
+		case l.Msg == "Purging cache":
+			ops = append(ops, Operation{Time: t, Op: OpPurge})
+		case strings.HasPrefix(l.Msg, "Case "):
+			key, value := l.entry()
+			if last == nil || last.Key != key || last.Case != 0 {
+				continue
+			}
+			last.Case, _ = strconv.Atoi(strings.TrimPrefix(l.Msg, "Case "))
+			if last.Op == OpPut {
+				// updates only log the key, the new value is in the item of the case
+				last.Value = value
+			}
+		case l.Msg == "Moving item from T1 to B1", l.Msg == "Moving item from T2 to B2":
+			if last == nil {
+				continue
+			}
+			key, _ := l.entry()
+			last.Demoted = append(last.Demoted, strings.ToLower(l.Msg[len("Moving item from "):][:2])+":"+key)
+		}
+	}
+	return ops, s.Err()
+}
+
+// entry returns the key and value of the entry logged in the line
+func (l *line) entry() (key, value string) {
+	if l.ItemKey != "" {
+		return l.ItemKey, l.ItemValue
+	}
+	return parseItem(l.Item)
+}
+
+// entryFields are the fields of a printed entry
+var entryFields = []string{"key", "value", "ll", "el", "ghost", "expires", "version", "pinned", "tags",
+	"inserted", "accessed", "accesses", "last"}
+
+// parseItem reads the key and value of an entry printed like "&{key:q value:w ll:<nil> ...}".
+// Fields are found by name and end where the next one starts, whatever their order.
+func parseItem(item string) (key, value string) {
+	if !strings.HasPrefix(item, "&{") {
+		return "", ""
+	}
+	item = strings.TrimSuffix(item[len("&{"):], "}")
+	return itemField(item, "key"), itemField(item, "value")
+}
+
+// itemField returns the field name of a printed entry
+func itemField(item, name string) string {
+	start := strings.Index(" "+item, " "+name+":")
+	if start < 0 {
+		return ""
+	}
+	start += len(name) + 1
+	end := len(item)
+	for _, f := range entryFields {
+		if j := strings.Index(item[start:], " "+f+":"); f != name && j >= 0 && start+j < end {
+			end = start + j
+		}
+	}
+	return item[start:end]
+}
@@ -0,0 +1,117 @@
+package logreplay
+
+import (
+	"container/list"
+	"fmt"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// Step is something that happened to a key during the replay
+type Step struct {
+	Time time.Time
+	// What is the operation on the key, or the type of event the cache emitted for it
+	What string
+	Case int
+	From string
+	To   string
+	// P is the target size of T1 after the step
+	P int
+}
+
+func (s Step) String() string {
+	str := s.Time.Format(TimeLayout) + " " + s.What
+	if s.Case != 0 && s.From == "" && s.To == "" {
+		// operations carry the case written to the log
+		str += fmt.Sprintf(", logged as case %d", s.Case)
+	} else if s.Case != 0 {
+		str += fmt.Sprintf(" case %d", s.Case)
+	}
+	if s.From != "" || s.To != "" {
+		str += fmt.Sprintf(" %s->%s", s.From, s.To)
+	}
+	return str + fmt.Sprintf(" p=%d", s.P)
+}
+
+// State is the cache rebuilt from the operations up to Time
+type State struct {
+	Time     time.Time
+	Applied  int
+	Snapshot arc.Snapshot
+	// History holds the steps of every key, oldest first
+	History map[string][]Step
+	// Mismatches lists the operations after which the rebuilt cache did not demote the keys
+	// the log shows. They hint at a wrong size, or at a log spanning several runs of the cache.
+	Mismatches []string
+}
+
+// recorder collects the events of the replayed cache
+type recorder struct {
+	events []arc.Event
+}
+
+func (r *recorder) Notify(e arc.Event) {
+	r.events = append(r.events, e)
+}
+
+// Rebuild replays the operations up to and including until into a cache holding c keys.
+// A zero until replays every operation.
+func Rebuild(ops []Operation, c int, until time.Time) *State {
+	rec := &recorder{}
+	cache := arc.NewARC(c, list.New(), list.New(), list.New(), list.New(),
+		arc.SetLogger(log.NewNopLogger()),
+		arc.SetObserver(rec),
+	)
+	state := &State{History: make(map[string][]Step)}
+
+	for _, op := range ops {
+		if !until.IsZero() && op.Time.After(until) {
+			break
+		}
+		rec.events = rec.events[:0]
+		switch op.Op {
+		case OpPut:
+			cache.Put(op.Key, op.Value)
+		case OpGet:
+			cache.Get(op.Key)
+		case OpDelete, OpExpire:
+			cache.Delete(op.Key)
+		case OpPurge:
+			cache.Purge()
+		}
+		state.Time = op.Time
+		state.Applied++
+
+		p := cache.Stats().P
+		if op.Key != "" {
+			state.History[op.Key] = append(state.History[op.Key], Step{Time: op.Time, What: op.Op, Case: op.Case, P: p})
+		}
+		var demoted []string
+		for _, e := range rec.events {
+			key := fmt.Sprint(e.Key)
+			if e.Type == arc.EventMiss || e.Type == arc.EventPChange {
+				continue
+			}
+			state.History[key] = append(state.History[key], Step{
+				Time: op.Time,
+				What: string(e.Type),
+				Case: e.Case,
+				From: e.From,
+				To:   e.To,
+				P:    e.P,
+			})
+			if e.Type == arc.EventDemotion {
+				demoted = append(demoted, e.From+":"+key)
+			}
+		}
+		// the case is only logged at debug level, without it the log says nothing of demotions
+		if op.Case != 0 && fmt.Sprint(demoted) != fmt.Sprint(op.Demoted) {
+			state.Mismatches = append(state.Mismatches, fmt.Sprintf("%s %s %s: log demoted %v, replay demoted %v",
+				op.Time.Format(TimeLayout), op.Op, op.Key, op.Demoted, demoted))
+		}
+	}
+	state.Snapshot = cache.Snapshot()
+	return state
+}
@@ -6,12 +6,16 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/deepak11627/arc/arc"
 	"github.com/deepak11627/arc/log"
 	"github.com/deepak11627/arc/models"
+	"github.com/deepak11627/arc/trace"
 	"github.com/deepak11627/arc/utils"
 )
 
@@ -20,12 +24,51 @@ var CacheSize int
 var debug bool
 var logPath string
 var dsn string
+var shadows string
+var eventsFile string
+var traceFile string
+var loadFile string
+var saveFile string
+var walDir string
+var walSync string
+var traceHash bool
+var traceSample float64
+var traceMaxSize int64
+var traceMaxFiles int
+var readBuffer int
+var admission bool
+var compactGhosts bool
+var pinnedCap int
+var keyIndex bool
+var invalidateGhosts bool
+var storeMode string
+var flushInterval time.Duration
 
 func init() {
 	// Initialise things here
 	flag.BoolVar(&debug, "debug", true, "Set the log level to debug")
 	flag.StringVar(&logPath, "log-path", "", "File path for log. Will attempt to create file but not directories. If empty (default) Stdout will be used.")
+	flag.IntVar(&CacheSize, "size", 0, "Maximum number of keys to cache. Asked for interactively when not set, required to serve.")
 	flag.StringVar(&dsn, "dsn", "", "The dsn string to connect to the database and allow storing lists in db.")
+	flag.StringVar(&eventsFile, "events-file", "", "File to append the cache events to as JSON lines. Disabled when empty.")
+	flag.StringVar(&loadFile, "load", "", "File to load the cache from at startup, as written by the save flag. Ignored when missing.")
+	flag.StringVar(&saveFile, "save", "", "File to save the cache to on exit, including on SIGINT and SIGTERM.")
+	flag.StringVar(&walDir, "wal-dir", "", "Directory of the write-ahead log the cache is recovered from at startup and records its changes to. Disabled when empty.")
+	flag.StringVar(&walSync, "wal-sync", "interval", "When the write-ahead log is synced to disk: always, interval (every second) or never.")
+	flag.StringVar(&traceFile, "trace-file", "", "File to record the Get and Put keys to, for the simulate command. Disabled when empty.")
+	flag.BoolVar(&traceHash, "trace-hash", false, "Record hashes of the keys in the trace rather than the keys themselves.")
+	flag.Float64Var(&traceSample, "trace-sample", 1, "Fraction of the keys to record in the trace, between 0 and 1.")
+	flag.Int64Var(&traceMaxSize, "trace-max-size", 0, "Size in bytes past which the trace file is rotated. Disabled when 0.")
+	flag.IntVar(&traceMaxFiles, "trace-max-files", 5, "Number of rotated trace files to keep.")
+	flag.BoolVar(&admission, "admission", false, "Only admit new keys accessed more often than the entry they would evict, to resist scans.")
+	flag.BoolVar(&compactGhosts, "compact-ghosts", false, "Keep B1 and B2 as 64-bit fingerprints of the keys rather than as entries.")
+	flag.IntVar(&pinnedCap, "pinned-cap", -1, "Maximum number of keys pinned at once, c/2 when negative.")
+	flag.BoolVar(&keyIndex, "key-index", false, "Keep the string keys sorted so that invalidating a prefix does not visit every key.")
+	flag.BoolVar(&invalidateGhosts, "invalidate-ghosts", false, "Also forget the ghosts of the keys invalidated by tag or prefix.")
+	flag.StringVar(&storeMode, "store", "", "Back the cache with the cache_entries table of the dsn database: write-through or write-back. Disabled when empty.")
+	flag.DurationVar(&flushInterval, "flush-interval", time.Second, "How often write-back saves the dirty values to the database, never when 0.")
+	flag.IntVar(&readBuffer, "read-buffer", 0, "Size of the buffers recording the hits served under a read lock, see the bench command. Every Get takes the write lock when 0.")
+	flag.StringVar(&shadows, "shadows", "", "Comma separated shadow caches to simulate next to the real one, e.g. \"arc:200,lru:100\".")
 
 }
 func main() {
@@ -38,33 +81,130 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Database
-	var database *models.Database
-	// dsn example "root:root@tcp(127.0.0.1:3306)/arc"
-	db, err := models.Open(dsn)
-	if err != nil {
-		logger.Error("unexpected error getting db connection", "err", err)
-		fmt.Println("DSN not provided.", err)
-		os.Exit(1)
-	} else {
-		database = models.NewDatabase(db, models.SetLogger(logger))
-		defer database.Close()
+	if flag.Arg(0) == "simulate" {
+		if err := Simulate(flag.Args()[1:]); err != nil {
+			fmt.Println("Problem simulating the trace.", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "bench" {
+		if err := Bench(flag.Args()[1:]); err != nil {
+			fmt.Println("Problem running the benchmark.", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "replay" {
+		if err := Replay(flag.Args()[1:]); err != nil {
+			fmt.Println("Problem replaying the log.", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	// Let's take cache size from user
-	utils.Message("Please enter maximum number of keys which caching system should store. ")
-	for CacheSize == 0 {
-		SetCacheSize()
+	if flag.Arg(0) != "serve" && CacheSize == 0 {
+		utils.Message("Please enter maximum number of keys which caching system should store. ")
+		for CacheSize == 0 {
+			SetCacheSize()
+		}
 	}
 
-	a := arc.NewARC(CacheSize,
-		list.New(),
-		list.New(),
-		list.New(),
-		list.New(),
-		arc.SetLogger(logger),
-		arc.SetDatabaseListService(models.NewGhostList(database)),
-	)
+	var opts []arc.Option
+	// closeEvents writes the events still buffered, before exiting
+	closeEvents := func() {}
+	if eventsFile != "" {
+		f, err := os.OpenFile(eventsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Println("Unable to open the events file.", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		observer := arc.NewJSONObserver(f)
+		closeEvents = func() { observer.Close() }
+		defer closeEvents()
+		opts = append(opts, arc.SetObserver(observer))
+	}
+	if traceFile != "" {
+		recorder, err := trace.NewRecorder(traceFile,
+			trace.SetLogger(logger),
+			trace.SetHashKeys(traceHash),
+			trace.SetSampleRate(traceSample),
+			trace.SetRotation(traceMaxSize, traceMaxFiles),
+		)
+		if err != nil {
+			fmt.Println("Unable to open the trace file.", err)
+			os.Exit(1)
+		}
+		closeRecorder = func() { recorder.Close() }
+		defer closeRecorder()
+		opts = append(opts, arc.SetTracer(recorder))
+	}
+	events := arc.NewEventBus()
+	if flag.Arg(0) == "serve" {
+		opts = append(opts, arc.SetObserver(events))
+	}
+
+	a, closeDB := NewCache(logger, opts...)
+	defer closeDB()
+
+	closeWAL := func() {}
+	if walDir != "" {
+		if closeWAL, err = OpenWAL(a, walDir, walSync); err != nil {
+			logger.Error("unable to open the write-ahead log", "dir", walDir, "err", err)
+			fmt.Println("Unable to open the write-ahead log.", err)
+			closeDB()
+			closeEvents()
+			exit(1)
+		}
+		defer closeWAL()
+	}
+
+	if loadFile != "" {
+		if err := LoadCache(a, loadFile); err != nil {
+			logger.Error("unable to load the cache, starting empty", "file", loadFile, "err", err)
+			fmt.Println("Unable to load the cache, starting empty.", err)
+		}
+	}
+	save := func() {
+		if saveFile == "" {
+			return
+		}
+		if err := SaveCache(a, saveFile); err != nil {
+			logger.Error("unable to save the cache", "file", saveFile, "err", err)
+			fmt.Println("Unable to save the cache.", err)
+		}
+	}
+
+	if flag.Arg(0) == "serve" {
+		if err := Serve(a, events, logger, flag.Args()[1:]); err != nil {
+			logger.Error("unexpected error serving the cache", "err", err)
+			fmt.Println("Problem serving the cache.", err)
+			closeWAL()
+			closeDB()
+			closeEvents()
+			exit(1)
+		}
+		save()
+		return
+	}
+
+	if saveFile != "" || walDir != "" {
+		// the menu blocks on stdin, so save from here when interrupted
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sig
+			save()
+			closeWAL()
+			closeDB()
+			closeEvents()
+			exit(0)
+		}()
+	}
 
 	for { // Keep the program executing until user chooses to exit
 		//prompt user to select an option
@@ -72,7 +212,7 @@ func main() {
 		switch option {
 		case 1:
 			key := ReadCache()
-			v, ok := a.Get(key)
+			v, ok := Read(a, key)
 			if ok {
 				utils.Message(fmt.Sprintf("Value at %s is %s \n", key, v))
 			} else {
@@ -84,15 +224,105 @@ func main() {
 			a.Put(k, v)
 		case 3:
 			a.Traverse()
+			ShowStats(a.Stats())
 		case 4:
+			key := ReadCache()
+			ShowKeyInfo(a, key)
+		case 5:
+			ListKeys(a, ReadPattern())
+		case 6:
 			utils.Message("Thank you. Exiting...")
-			os.Exit(0)
+			// return rather than exit so that the trace and database are closed
+			save()
+			return
 		default:
 			utils.Message("Program error.")
-			os.Exit(1)
+			exit(1)
+		}
+	}
+
+}
+
+// closeRecorder writes the trace records still buffered, before exiting
+var closeRecorder = func() {}
+
+// exit closes the trace recorder, whose buffered records os.Exit would lose, and exits with code
+func exit(code int) {
+	closeRecorder()
+	os.Exit(code)
+}
+
+// NewCache builds the ARC from the flags and extra options, storing the ghost lists in the database
+// when a dsn is given. The returned function closes the database connection.
+func NewCache(logger arc.Logger, extra ...arc.Option) (arc.CacheService, func()) {
+	shadowCaches, err := ParseShadows(shadows)
+	if err != nil {
+		fmt.Println("Invalid shadows flag.", err)
+		exit(1)
+	}
+
+	opts := []arc.Option{
+		arc.SetLogger(logger),
+		arc.SetShadows(shadowCaches...),
+		arc.SetReadBuffer(readBuffer),
+		arc.SetAdmission(admission),
+		arc.SetCompactGhosts(compactGhosts),
+		arc.SetPinnedCap(pinnedCap),
+		arc.SetKeyIndex(keyIndex),
+		arc.SetInvalidateGhosts(invalidateGhosts),
+	}
+	opts = append(opts, extra...)
+
+	var mode arc.WriteMode
+	switch storeMode {
+	case "":
+	case "write-through":
+		mode = arc.WriteThrough
+	case "write-back":
+		mode = arc.WriteBack
+	default:
+		fmt.Println("Invalid store flag, use write-through or write-back.")
+		exit(1)
+	}
+	if mode != 0 && dsn == "" {
+		fmt.Println("The store flag needs a dsn.")
+		exit(1)
+	}
+
+	// Database
+	var cache arc.CacheService
+	closeDB := func() {}
+	if dsn != "" {
+		// dsn example "root:root@tcp(127.0.0.1:3306)/arc"
+		db, err := models.Open(dsn)
+		if err != nil {
+			logger.Error("unexpected error getting db connection", "err", err)
+			fmt.Println("Unable to connect to the database.", err)
+			exit(1)
+		}
+		database := models.NewDatabase(db, models.SetLogger(logger))
+		closeDB = func() { database.Close() }
+		opts = append(opts, arc.SetDatabaseListService(models.NewGhostList(database)))
+		if mode != 0 {
+			opts = append(opts, arc.SetStore(models.NewEntryStore(database), mode), arc.SetFlushInterval(flushInterval))
+			// save the dirty values before the connection goes
+			closeDB = func() {
+				if err := cache.(arc.StoreService).CloseStore(); err != nil {
+					logger.Error("unable to save the cache to the database", "err", err)
+				}
+				database.Close()
+			}
 		}
 	}
 
+	cache = arc.NewARC(CacheSize,
+		list.New(),
+		list.New(),
+		list.New(),
+		list.New(),
+		opts...,
+	)
+	return cache, closeDB
 }
 
 func ReadCache() interface{} {
@@ -103,6 +333,50 @@ func ReadCache() interface{} {
 	return k
 }
 
+// Read returns the value of key, loading it from the store on a miss when the store flag is set
+func Read(a arc.CacheService, key interface{}) (interface{}, bool) {
+	if loader, ok := a.(arc.LoadService); ok && storeMode != "" {
+		v, err := loader.GetOrLoad(key)
+		if err != nil && err != arc.ErrNotFound {
+			utils.Message(fmt.Sprintf("Unable to load the key. %s\n", err))
+		}
+		return v, err == nil
+	}
+	return a.Get(key)
+}
+
+func ReadPattern() string {
+	utils.Message("Please enter a pattern like user:*, or nothing to list every key ")
+	reader := bufio.NewReader(os.Stdin)
+	p, _ := reader.ReadString('\n')
+	return strings.Replace(p, "\n", "", -1)
+}
+
+// ListKeys prints the keys matching the glob pattern, a page at a time, without changing their recency
+func ListKeys(a arc.CacheService, pattern string) {
+	scanner, ok := a.(arc.ScanService)
+	if !ok {
+		utils.Message("The keys of the cache cannot be listed.\n")
+		return
+	}
+	utils.RenderMessageHeading("Keys of the cache.")
+	fmt.Println()
+	n := 0
+	for cursor := uint64(0); ; {
+		var keys []interface{}
+		keys, cursor = scanner.Scan(cursor, pattern, 100)
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+		n += len(keys)
+		if cursor == 0 {
+			break
+		}
+	}
+	fmt.Printf("%d keys\n", n)
+	utils.RenderMessageEnd()
+}
+
 func GetKeyValuePair() (interface{}, interface{}) {
 	utils.Message("Please enter key ")
 	reader := bufio.NewReader(os.Stdin)
@@ -114,6 +388,79 @@ func GetKeyValuePair() (interface{}, interface{}) {
 	return k, v
 }
 
+// ParseShadows builds the shadow caches described by a list like "arc:200,lru:100"
+func ParseShadows(val string) ([]arc.Shadow, error) {
+	var shadows []arc.Shadow
+	for _, s := range strings.Split(val, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("shadow %q must look like policy:size", s)
+		}
+		size, err := strconv.Atoi(parts[1])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("shadow %q must have a positive size", s)
+		}
+		switch parts[0] {
+		case "arc":
+			shadows = append(shadows, arc.NewARCShadow(size))
+		case "lru":
+			shadows = append(shadows, arc.NewLRUShadow(size))
+		default:
+			return nil, fmt.Errorf("shadow %q has an unknown policy, use arc or lru", s)
+		}
+	}
+	return shadows, nil
+}
+
+// ShowStats prints the hit ratio of the cache and of its shadows
+func ShowStats(stats arc.Stats) {
+	utils.RenderMessageHeading("Cache statistics.")
+	fmt.Printf("\ncache: hits %d, misses %d, hit ratio %.2f\n", stats.Hits, stats.Misses, stats.HitRatio())
+	if stats.Rejected > 0 {
+		fmt.Printf("cache: %d new keys rejected by the admission policy\n", stats.Rejected)
+	}
+	for _, s := range stats.Shadows {
+		fmt.Printf("shadow %s: hits %d, misses %d, hit ratio %.2f\n", s.Name, s.Hits, s.Misses, s.HitRatio())
+	}
+	utils.RenderMessageEnd()
+}
+
+// ShowKeyInfo prints where key is in the cache and how it got there
+func ShowKeyInfo(a arc.CacheService, key interface{}) {
+	inspector, ok := a.(arc.InspectService)
+	if !ok {
+		utils.Message("The cache cannot be inspected.\n")
+		return
+	}
+	info, ok := inspector.Inspect(key)
+	if !ok {
+		utils.Message("The key is not cached and was not dropped recently, it may never have been inserted.\n")
+		return
+	}
+	utils.RenderMessageHeading(fmt.Sprintf("Key %v.", info.Key))
+	if info.List != "" {
+		fmt.Printf("\nlist %s, inserted %s, last access %s, %d accesses\n", info.List,
+			info.Inserted.Format(time.RFC3339), info.LastAccess.Format(time.RFC3339), info.Accesses)
+	} else {
+		fmt.Printf("\ndropped, inserted %s, last access %s, %d accesses\n",
+			info.Inserted.Format(time.RFC3339), info.LastAccess.Format(time.RFC3339), info.Accesses)
+	}
+	last := info.Last
+	fmt.Printf("last moved at %s from %q to %q", last.Time.Format(time.RFC3339), last.From, last.To)
+	if last.Case != 0 {
+		fmt.Printf(" by case %d", last.Case)
+	}
+	if last.Reason != "" {
+		fmt.Printf(" (%s)", last.Reason)
+	}
+	fmt.Printf(", p was then %d", last.P)
+	utils.RenderMessageEnd()
+}
+
 //SetCacheSize takes input from user and sets value for CacheSize
 func SetCacheSize() {
 	reader := bufio.NewReader(os.Stdin)
@@ -137,7 +484,9 @@ func showOptions() int {
 	utils.Message("Press 1 for getting a value from cache.")
 	utils.Message("Press 2 for adding a value into cache.")
 	utils.Message("Press 3 to view the cache items")
-	utils.Message("Press 4 to Exit the program.")
+	utils.Message("Press 4 to inspect a key.")
+	utils.Message("Press 5 to list the keys.")
+	utils.Message("Press 6 to Exit the program.")
 	utils.RenderMessageEnd()
 	notAnOption := true
 	var selection int
@@ -146,17 +495,17 @@ func showOptions() int {
 		val, err := reader.ReadString('\n')
 		if err != nil {
 			utils.Message("Problem reading the entered value.")
-			os.Exit(1)
+			exit(1)
 		}
 		val = strings.Replace(val, "\n", "", -1)
 		selection, err = strconv.Atoi(val)
 		if err != nil {
-			utils.Message("1,2,3 or 4 are the only accepted values.")
+			utils.Message("1,2,3,4,5 or 6 are the only accepted values.")
 		} else {
-			if selection == 1 || selection == 2 || selection == 3 || selection == 4 {
+			if selection >= 1 && selection <= 6 {
 				notAnOption = false
 			} else {
-				utils.Message("1,2,3 or 4 are the only accepted values.")
+				utils.Message("1,2,3,4,5 or 6 are the only accepted values.")
 			}
 		}
 
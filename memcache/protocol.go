@@ -0,0 +1,532 @@
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+const (
+	// maxKeyLength is the longest key memcached accepts
+	maxKeyLength = 250
+	// maxItemSize is the largest value accepted by a storage command
+	maxItemSize = 1 << 20
+	// maxRelativeExptime is the largest exptime read as seconds from now, larger ones are unix times
+	maxRelativeExptime = 60 * 60 * 24 * 30
+
+	version = "1.0.0-arc"
+)
+
+var (
+	errLineTooLong = errors.New("memcache: line too long")
+	errBadChunk    = errors.New("bad data chunk")
+)
+
+// readLine reads a command line without its trailing \r\n
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// dispatch runs a single command, it reports whether the connection should be closed
+func (s *Server) dispatch(line string, r *bufio.Reader, w *bufio.Writer) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		w.WriteString("ERROR\r\n")
+		return false
+	}
+
+	args := fields[1:]
+	switch fields[0] {
+	case "get":
+		s.get(args, w, false)
+	case "gets":
+		s.get(args, w, true)
+	case "set", "add", "replace", "cas":
+		return s.store(fields[0], args, r, w)
+	case "delete":
+		s.delete(args, w)
+	case "touch":
+		s.touch(args, w)
+	case "incr", "decr":
+		s.incr(fields[0] == "incr", args, w)
+	case "stats":
+		s.stats(w)
+	case "flush_all":
+		s.flushAll(args, w)
+	case "version":
+		w.WriteString("VERSION " + version + "\r\n")
+	case "verbosity":
+		reply(w, noreply(args), "OK")
+	case "quit":
+		return true
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return false
+}
+
+func (s *Server) get(keys []string, w *bufio.Writer, withCAS bool) {
+	if len(keys) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		atomic.AddUint64(&s.cmdGet, 1)
+		it, cas, ok := s.lookup(key)
+		if !ok {
+			continue
+		}
+		if withCAS {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.Flags, len(it.Data), cas)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.Flags, len(it.Data))
+		}
+		w.Write(it.Data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+// store handles set, add, replace and cas which all carry a data block
+func (s *Server) store(cmd string, args []string, r *bufio.Reader, w *bufio.Writer) bool {
+	quiet := noreply(args)
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	want := 4
+	if cmd == "cas" {
+		want = 5
+	}
+	if len(args) != want {
+		w.WriteString("ERROR\r\n")
+		return false
+	}
+
+	key := args[0]
+	flags, err1 := strconv.ParseUint(args[1], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+	size, err3 := strconv.Atoi(args[3])
+	if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+	var unique uint64
+	if cmd == "cas" {
+		var err error
+		if unique, err = strconv.ParseUint(args[4], 10, 64); err != nil {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false
+		}
+	}
+	if size > maxItemSize {
+		// swallow the data block so the connection stays usable
+		if _, err := io.CopyN(ioutil.Discard, r, int64(size)+2); err != nil {
+			return true
+		}
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return false
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return true
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		w.WriteString("CLIENT_ERROR " + errBadChunk.Error() + "\r\n")
+		return false
+	}
+	if !validKey(key) {
+		w.WriteString("CLIENT_ERROR bad key\r\n")
+		return false
+	}
+	atomic.AddUint64(&s.cmdSet, 1)
+
+	it := &Item{Flags: uint32(flags), Data: data[:size]}
+	if s.versions != nil && (cmd == "add" || cmd == "cas") {
+		reply(w, quiet, s.storeVersioned(cmd, key, it, unique, exptime))
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch cmd {
+	case "add":
+		if s.exists(key) {
+			reply(w, quiet, "NOT_STORED")
+			return false
+		}
+	case "replace":
+		if !s.exists(key) {
+			reply(w, quiet, "NOT_STORED")
+			return false
+		}
+	case "cas":
+		_, cas, ok := s.lookup(key)
+		if !ok {
+			reply(w, quiet, "NOT_FOUND")
+			return false
+		}
+		if cas != unique {
+			reply(w, quiet, "EXISTS")
+			return false
+		}
+	}
+
+	if s.versions == nil {
+		it.CAS = s.nextCAS()
+	}
+	switch err := s.put(key, it, exptime); err {
+	case nil:
+		reply(w, quiet, "STORED")
+	case arc.ErrRejected:
+		reply(w, quiet, "NOT_STORED")
+	default:
+		reply(w, quiet, "SERVER_ERROR "+err.Error())
+	}
+	return false
+}
+
+// storeVersioned runs add and cas with the atomic operations of the cache, and returns the reply
+func (s *Server) storeVersioned(cmd, key string, it *Item, unique uint64, exptime int64) string {
+	ttl, expired := ttlFor(exptime)
+	switch cmd {
+	case "add":
+		if s.admission != nil && !expired {
+			// the expiry is set along with the value and the admission policy applies, like set
+			stored, err := s.admission.TryPutIfAbsent(key, it, ttl)
+			switch {
+			case err == arc.ErrRejected || (err == nil && !stored):
+				return "NOT_STORED"
+			case err != nil:
+				return "SERVER_ERROR " + err.Error()
+			}
+			return "STORED"
+		}
+		if !s.versions.PutIfAbsent(key, it) {
+			return "NOT_STORED"
+		}
+	case "cas":
+		switch _, err := s.versions.CompareAndSwap(key, unique, it); err {
+		case nil:
+		case arc.ErrNotFound:
+			return "NOT_FOUND"
+		case arc.ErrVersionMismatch:
+			return "EXISTS"
+		default:
+			return "SERVER_ERROR " + err.Error()
+		}
+	}
+	if expired {
+		s.cache.Delete(key)
+	} else if s.expiry != nil {
+		// a zero ttl also clears the expiry a cas keeps from the previous value
+		s.expiry.Touch(key, ttl)
+	}
+	return "STORED"
+}
+
+func (s *Server) delete(args []string, w *bufio.Writer) {
+	quiet := noreply(args)
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	// a trailing time of 0 is still accepted by memcached for old clients
+	if len(args) == 2 && args[1] == "0" {
+		args = args[:1]
+	}
+	if len(args) != 1 {
+		w.WriteString("CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]\r\n")
+		return
+	}
+	if s.cache.Delete(args[0]) {
+		reply(w, quiet, "DELETED")
+	} else {
+		reply(w, quiet, "NOT_FOUND")
+	}
+}
+
+func (s *Server) touch(args []string, w *bufio.Writer) {
+	quiet := noreply(args)
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	if len(args) != 2 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+	atomic.AddUint64(&s.cmdTouch, 1)
+
+	key := args[0]
+	ttl, expired := ttlFor(exptime)
+	touched := false
+	if s.expiry == nil {
+		touched = s.exists(key)
+	} else if expired {
+		touched = s.cache.Delete(key)
+	} else {
+		touched = s.expiry.Touch(key, ttl)
+	}
+	if touched {
+		reply(w, quiet, "TOUCHED")
+	} else {
+		reply(w, quiet, "NOT_FOUND")
+	}
+}
+
+func (s *Server) incr(incr bool, args []string, w *bufio.Writer) {
+	quiet := noreply(args)
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	if len(args) != 2 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+
+	key := args[0]
+	if s.updates != nil {
+		var val string
+		_, err := s.updates.Update(key, func(v interface{}, ok bool) (interface{}, error) {
+			if !ok {
+				return nil, arc.ErrNotFound
+			}
+			it := toItem(v)
+			var err error
+			val, err = count(it, incr, delta)
+			if err != nil {
+				return nil, arc.ErrNotNumber
+			}
+			return &Item{Flags: it.Flags, Data: []byte(val)}, nil
+		})
+		switch err {
+		case nil:
+			reply(w, quiet, val)
+		case arc.ErrNotFound:
+			reply(w, quiet, "NOT_FOUND")
+		case arc.ErrNotNumber:
+			w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+		default:
+			reply(w, quiet, "SERVER_ERROR "+err.Error())
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	it, _, ok := s.lookup(key)
+	if !ok {
+		reply(w, quiet, "NOT_FOUND")
+		return
+	}
+	val, err := count(it, incr, delta)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+		return
+	}
+	updated := &Item{Flags: it.Flags, Data: []byte(val)}
+	if s.versions == nil {
+		updated.CAS = s.nextCAS()
+	}
+	if s.expiry != nil {
+		ttl, _ := s.expiry.TTL(key)
+		s.expiry.PutWithTTL(key, updated, ttl)
+	} else {
+		s.cache.Put(key, updated)
+	}
+	reply(w, quiet, val)
+}
+
+// count applies incr or decr to the number held by it, memcached wraps around on
+// overflow and stops at 0 on underflow
+func count(it *Item, incr bool, delta uint64) (string, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(string(it.Data)), 10, 64)
+	if err != nil {
+		return "", err
+	}
+	if incr {
+		n += delta
+	} else if delta > n {
+		n = 0
+	} else {
+		n -= delta
+	}
+	return strconv.FormatUint(n, 10), nil
+}
+
+func (s *Server) stats(w *bufio.Writer) {
+	stats := s.cache.Stats()
+	now := time.Now()
+
+	s.connsMutex.Lock()
+	conns := len(s.conns)
+	s.connsMutex.Unlock()
+
+	stat := func(name string, v interface{}) {
+		fmt.Fprintf(w, "STAT %s %v\r\n", name, v)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(s.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", version)
+	stat("curr_connections", conns)
+	stat("curr_items", s.cache.Len())
+	stat("cmd_get", atomic.LoadUint64(&s.cmdGet))
+	stat("cmd_set", atomic.LoadUint64(&s.cmdSet))
+	stat("cmd_touch", atomic.LoadUint64(&s.cmdTouch))
+	stat("cmd_flush", atomic.LoadUint64(&s.cmdFlush))
+	stat("get_hits", stats.Hits)
+	stat("get_misses", stats.Misses)
+	w.WriteString("END\r\n")
+}
+
+func (s *Server) flushAll(args []string, w *bufio.Writer) {
+	quiet := noreply(args)
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	delay := int64(0)
+	if len(args) > 0 {
+		var err error
+		if delay, err = strconv.ParseInt(args[0], 10, 64); err != nil || len(args) > 1 {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+	}
+	atomic.AddUint64(&s.cmdFlush, 1)
+
+	if delay > 0 {
+		time.AfterFunc(time.Duration(delay)*time.Second, s.cache.Purge)
+	} else {
+		s.cache.Purge()
+	}
+	reply(w, quiet, "OK")
+}
+
+// lookup returns the item stored at key with its cas unique
+func (s *Server) lookup(key string) (*Item, uint64, bool) {
+	var v interface{}
+	var version uint64
+	var ok bool
+	if s.versions != nil {
+		v, version, ok = s.versions.GetWithVersion(key)
+	} else {
+		v, ok = s.cache.Get(key)
+	}
+	if !ok {
+		return nil, 0, false
+	}
+	it := toItem(v)
+	if s.versions == nil {
+		version = it.CAS
+	}
+	return it, version, true
+}
+
+// toItem presents values put in the cache by other front-ends as items without flags
+func toItem(v interface{}) *Item {
+	switch v := v.(type) {
+	case *Item:
+		return v
+	case []byte:
+		return &Item{Data: v}
+	case string:
+		return &Item{Data: []byte(v)}
+	}
+	return &Item{Data: []byte(fmt.Sprint(v))}
+}
+
+// exists reports whether key is cached, without affecting its position when the cache supports expiry
+func (s *Server) exists(key string) bool {
+	if s.expiry != nil {
+		_, ok := s.expiry.TTL(key)
+		return ok
+	}
+	_, ok := s.cache.Get(key)
+	return ok
+}
+
+// put stores it at key, it returns arc.ErrRejected when the admission policy of the cache keeps
+// the key out
+func (s *Server) put(key string, it *Item, exptime int64) error {
+	ttl, expired := ttlFor(exptime)
+	if expired {
+		s.cache.Delete(key)
+		return nil
+	}
+	if s.admission != nil {
+		_, err := s.admission.TryPut(key, it, ttl)
+		return err
+	}
+	if s.expiry != nil {
+		s.expiry.PutWithTTL(key, it, ttl)
+		return nil
+	}
+	s.cache.Put(key, it)
+	return nil
+}
+
+// ttlFor converts a memcached exptime into a time to live. Zero never expires,
+// negative values are already expired, values up to 30 days are relative and
+// larger ones are unix timestamps.
+func ttlFor(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= maxRelativeExptime:
+		return time.Duration(exptime) * time.Second, false
+	}
+	ttl := time.Unix(exptime, 0).Sub(time.Now())
+	return ttl, ttl <= 0
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func noreply(args []string) bool {
+	return len(args) > 0 && args[len(args)-1] == "noreply"
+}
+
+func reply(w *bufio.Writer, quiet bool, msg string) {
+	if !quiet {
+		w.WriteString(msg + "\r\n")
+	}
+}
@@ -0,0 +1,312 @@
+package memcache
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// step sends a request and expects exactly the reply want
+type step struct {
+	send string
+	want string
+}
+
+var errUnavailable = errors.New("store unavailable")
+
+// flakyStore holds nothing and fails every save while failing is set
+type flakyStore struct {
+	failing int32
+}
+
+func (s *flakyStore) Load(key interface{}) (interface{}, error) {
+	return nil, arc.ErrNotFound
+}
+
+func (s *flakyStore) Save(key, value interface{}) error {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		return errUnavailable
+	}
+	return nil
+}
+
+func (s *flakyStore) Delete(key interface{}) error {
+	return nil
+}
+
+func (s *flakyStore) fail(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&s.failing, v)
+}
+
+func newCache(c int, opts ...arc.Option) arc.CacheService {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	return arc.NewARC(c, list.New(), list.New(), list.New(), list.New(), opts...)
+}
+
+// dial serves cache to one end of an in-process connection and returns the other
+func dial(t *testing.T, cache arc.CacheService) net.Conn {
+	s := NewServer("", cache)
+	client, conn := net.Pipe()
+	s.track(conn, true)
+	s.wg.Add(1)
+	go s.serveConn(conn)
+	t.Cleanup(func() {
+		client.Close()
+		s.Close()
+	})
+	return client
+}
+
+// roundTrip sends req and reads a reply of the length of want
+func roundTrip(t *testing.T, c net.Conn, req, want string) string {
+	t.Helper()
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	go c.Write([]byte(req))
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("%q: reading the reply: %v, got %q", req, err, got)
+	}
+	return string(got)
+}
+
+func run(t *testing.T, c net.Conn, steps []step) {
+	t.Helper()
+	for _, st := range steps {
+		if got := roundTrip(t, c, st.send, st.want); got != st.want {
+			t.Fatalf("%q: got %q, want %q", st.send, got, st.want)
+		}
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"set and get with flags", []step{
+			{"set k 42 0 5\r\nhello\r\n", "STORED\r\n"},
+			{"get k\r\n", "VALUE k 42 5\r\nhello\r\nEND\r\n"},
+			{"get missing k\r\n", "VALUE k 42 5\r\nhello\r\nEND\r\n"},
+		}},
+		{"get without keys", []step{
+			{"get\r\n", "ERROR\r\n"},
+		}},
+		{"empty value", []step{
+			{"set k 0 0 0\r\n\r\n", "STORED\r\n"},
+			{"get k\r\n", "VALUE k 0 0\r\n\r\nEND\r\n"},
+		}},
+		{"add", []step{
+			{"add k 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"add k 0 0 1\r\nb\r\n", "NOT_STORED\r\n"},
+			{"get k\r\n", "VALUE k 0 1\r\na\r\nEND\r\n"},
+		}},
+		{"replace", []step{
+			{"replace k 0 0 1\r\na\r\n", "NOT_STORED\r\n"},
+			{"set k 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"replace k 7 0 1\r\nb\r\n", "STORED\r\n"},
+			{"get k\r\n", "VALUE k 7 1\r\nb\r\nEND\r\n"},
+		}},
+		{"cas on a missing key", []step{
+			{"cas k 0 0 1 1\r\na\r\n", "NOT_FOUND\r\n"},
+		}},
+		{"delete", []step{
+			{"set k 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"delete k\r\n", "DELETED\r\n"},
+			{"delete k\r\n", "NOT_FOUND\r\n"},
+			{"set k 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"delete k 0\r\n", "DELETED\r\n"},
+			{"delete k 1\r\n", "CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]\r\n"},
+		}},
+		{"touch", []step{
+			{"touch k 10\r\n", "NOT_FOUND\r\n"},
+			{"set k 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"touch k 10\r\n", "TOUCHED\r\n"},
+			{"touch k x\r\n", "CLIENT_ERROR invalid exptime argument\r\n"},
+			{"touch k -1\r\n", "TOUCHED\r\n"},
+			{"get k\r\n", "END\r\n"},
+		}},
+		{"incr and decr", []step{
+			{"incr n 1\r\n", "NOT_FOUND\r\n"},
+			{"set n 3 0 2\r\n10\r\n", "STORED\r\n"},
+			{"incr n 5\r\n", "15\r\n"},
+			{"decr n 20\r\n", "0\r\n"},
+			{"incr n 18446744073709551615\r\n", "18446744073709551615\r\n"},
+			{"incr n 2\r\n", "1\r\n"},
+			{"get n\r\n", "VALUE n 3 1\r\n1\r\nEND\r\n"},
+			{"incr n x\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n"},
+			{"set s 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"decr s 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"},
+		}},
+		{"flush_all", []step{
+			{"set a 0 0 1\r\na\r\nset b 0 0 1\r\nb\r\n", "STORED\r\nSTORED\r\n"},
+			{"flush_all\r\n", "OK\r\n"},
+			{"get a b\r\n", "END\r\n"},
+			{"set a 0 0 1\r\na\r\n", "STORED\r\n"},
+			{"flush_all noreply\r\nget a\r\n", "END\r\n"},
+			{"flush_all x\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		}},
+		{"negative exptime", []step{
+			{"set k 0 -1 1\r\na\r\n", "STORED\r\n"},
+			{"get k\r\n", "END\r\n"},
+		}},
+		{"past unix exptime", []step{
+			{"set k 0 1000000000 1\r\na\r\n", "STORED\r\n"},
+			{"get k\r\n", "END\r\n"},
+		}},
+		{"relative and future unix exptime", []step{
+			{"set a 0 60 1\r\na\r\n", "STORED\r\n"},
+			{fmt.Sprintf("set b 0 %d 1\r\nb\r\n", future), "STORED\r\n"},
+			{"get a b\r\n", "VALUE a 0 1\r\na\r\nVALUE b 0 1\r\nb\r\nEND\r\n"},
+		}},
+		{"noreply", []step{
+			{"set k 0 0 1 noreply\r\na\r\nget k\r\n", "VALUE k 0 1\r\na\r\nEND\r\n"},
+			{"add k 0 0 1 noreply\r\nb\r\nreplace k 0 0 1 noreply\r\n5\r\nget k\r\n", "VALUE k 0 1\r\n5\r\nEND\r\n"},
+			{"incr k 1 noreply\r\ntouch k 10 noreply\r\ndelete k noreply\r\nget k\r\n", "END\r\n"},
+		}},
+		{"pipelined", []step{
+			{"set a 0 0 1\r\na\r\nget a\r\ndelete a\r\n", "STORED\r\nVALUE a 0 1\r\na\r\nEND\r\nDELETED\r\n"},
+		}},
+		{"bad data chunk", []step{
+			{"set k 0 0 3\r\nabcXY", "CLIENT_ERROR bad data chunk\r\n"},
+			{"get k\r\n", "END\r\n"},
+		}},
+		{"bad command lines", []step{
+			{"set k 0 0\r\n", "ERROR\r\n"},
+			{"set k x 0 1\r\n", "CLIENT_ERROR bad command line format\r\n"},
+			{"set k 0 0 -1\r\n", "CLIENT_ERROR bad command line format\r\n"},
+			{"cas k 0 0 1 x\r\n", "CLIENT_ERROR bad command line format\r\n"},
+			{"bogus\r\n", "ERROR\r\n"},
+			{"\r\n", "ERROR\r\n"},
+			{"version\r\n", "VERSION " + version + "\r\n"},
+		}},
+		{"bad key", []step{
+			{"set " + strings.Repeat("k", maxKeyLength+1) + " 0 0 1\r\na\r\n", "CLIENT_ERROR bad key\r\n"},
+		}},
+		{"object too large", []step{
+			{fmt.Sprintf("set k 0 0 %d\r\n%s\r\n", maxItemSize+1, strings.Repeat("a", maxItemSize+1)), "SERVER_ERROR object too large for cache\r\n"},
+			{"get k\r\n", "END\r\n"},
+		}},
+		{"line too long", []step{
+			{strings.Repeat("a", 5000), "CLIENT_ERROR line too long\r\n"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, dial(t, newCache(10)), tt.steps)
+		})
+	}
+}
+
+func TestGetsAndCas(t *testing.T) {
+	cache := newCache(10)
+	c := dial(t, cache)
+	run(t, c, []step{{"set k 5 0 1\r\na\r\n", "STORED\r\n"}})
+
+	unique, _ := cache.(arc.VersionService).Version("k")
+	run(t, c, []step{
+		{"gets k\r\n", fmt.Sprintf("VALUE k 5 1 %d\r\na\r\nEND\r\n", unique)},
+		{fmt.Sprintf("cas k 6 0 1 %d\r\nb\r\n", unique+1), "EXISTS\r\n"},
+		{fmt.Sprintf("cas k 6 0 1 %d\r\nb\r\n", unique), "STORED\r\n"},
+		{fmt.Sprintf("cas k 6 0 1 %d noreply\r\nc\r\nget k\r\n", unique), "VALUE k 6 1\r\nb\r\nEND\r\n"},
+	})
+}
+
+func TestCasStoreError(t *testing.T) {
+	store := &flakyStore{}
+	cache := newCache(10, arc.SetStore(store, arc.WriteThrough))
+	c := dial(t, cache)
+	run(t, c, []step{{"set k 0 0 1\r\na\r\n", "STORED\r\n"}})
+
+	unique, _ := cache.(arc.VersionService).Version("k")
+	store.fail(true)
+	run(t, c, []step{
+		{fmt.Sprintf("cas k 0 0 1 %d\r\nb\r\n", unique), "SERVER_ERROR " + errUnavailable.Error() + "\r\n"},
+		{"get k\r\n", "VALUE k 0 1\r\na\r\nEND\r\n"},
+	})
+}
+
+func TestIncrStoreError(t *testing.T) {
+	store := &flakyStore{}
+	c := dial(t, newCache(10, arc.SetStore(store, arc.WriteThrough)))
+	run(t, c, []step{{"set n 0 0 1\r\n1\r\n", "STORED\r\n"}})
+	store.fail(true)
+	run(t, c, []step{
+		{"incr n 1\r\n", "SERVER_ERROR " + errUnavailable.Error() + "\r\n"},
+		{"get n\r\n", "VALUE n 0 1\r\n1\r\nEND\r\n"},
+	})
+}
+
+func TestExptime(t *testing.T) {
+	cache := newCache(10)
+	run(t, dial(t, cache), []step{
+		{"set k 0 100 1\r\na\r\n", "STORED\r\n"},
+	})
+	ttl, ok := cache.(arc.ExpiryService).TTL("k")
+	if !ok || ttl <= 90*time.Second || ttl > 100*time.Second {
+		t.Fatalf("got a ttl of %v, want about 100s", ttl)
+	}
+}
+
+func TestAdmissionRejected(t *testing.T) {
+	cache := newCache(2, arc.SetAdmission(true))
+	c := dial(t, cache)
+	run(t, c, []step{
+		{"set a 0 0 1\r\na\r\nset b 0 0 1\r\nb\r\n", "STORED\r\nSTORED\r\n"},
+	})
+	for i := 0; i < 5; i++ {
+		run(t, c, []step{{"get a b\r\n", "VALUE a 0 1\r\na\r\nVALUE b 0 1\r\nb\r\nEND\r\n"}})
+	}
+	run(t, c, []step{
+		{"set c 0 0 1\r\nc\r\n", "NOT_STORED\r\n"},
+		{"add c 0 0 1\r\nc\r\n", "NOT_STORED\r\n"},
+		{"get c\r\n", "END\r\n"},
+	})
+}
+
+func TestAddExptime(t *testing.T) {
+	cache := newCache(10)
+	run(t, dial(t, cache), []step{
+		{"add k 0 100 1\r\na\r\n", "STORED\r\n"},
+		{"add k 0 100 1\r\nb\r\n", "NOT_STORED\r\n"},
+		{"add e 0 -1 1\r\na\r\n", "STORED\r\n"},
+		{"get k e\r\n", "VALUE k 0 1\r\na\r\nEND\r\n"},
+	})
+	ttl, ok := cache.(arc.ExpiryService).TTL("k")
+	if !ok || ttl <= 90*time.Second || ttl > 100*time.Second {
+		t.Fatalf("got a ttl of %v, want about 100s", ttl)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	cache := newCache(10)
+	run(t, dial(t, cache), []step{
+		{"set k 1 0 5\r\nhello\r\n", "STORED\r\n"},
+	})
+	var buf bytes.Buffer
+	if err := cache.(arc.PersistService).Save(&buf); err != nil {
+		t.Fatalf("saving: %v", err)
+	}
+
+	loaded := newCache(10)
+	if err := loaded.(arc.PersistService).Load(&buf); err != nil {
+		t.Fatalf("loading: %v", err)
+	}
+	run(t, dial(t, loaded), []step{
+		{"get k\r\n", "VALUE k 1 5\r\nhello\r\nEND\r\n"},
+	})
+}
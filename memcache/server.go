@@ -0,0 +1,182 @@
+// Package memcache serves a cache over the memcached ASCII protocol
+package memcache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// Server speaks the memcached text protocol on top of a cache
+type Server struct {
+	addr      string
+	cache     arc.CacheService
+	expiry    arc.ExpiryService
+	versions  arc.VersionService
+	updates   arc.UpdateService
+	admission arc.AdmissionService
+	logger    arc.Logger
+	started   time.Time
+	listener  net.Listener
+
+	// mutex makes the read-modify-write commands (add, replace, cas, incr, decr) atomic
+	// for caches without versions or updates
+	mutex sync.Mutex
+	cas   uint64
+
+	connsMutex sync.Mutex
+	conns      map[net.Conn]struct{}
+	wg         sync.WaitGroup
+	closed     int32
+
+	cmdGet   uint64
+	cmdSet   uint64
+	cmdTouch uint64
+	cmdFlush uint64
+}
+
+// Option type setting params dynamically
+type Option func(*Server)
+
+// SetLogger function to set logger dynamically
+func SetLogger(l arc.Logger) func(*Server) {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// Item is the value stored in the cache for every memcached key. It is registered with gob so
+// that the default codec of the cache saves and logs it.
+type Item struct {
+	Flags uint32
+	Data  []byte
+	// CAS is only set for caches without versions, the version of the entry is used otherwise
+	CAS uint64
+}
+
+func init() {
+	gob.Register(&Item{})
+}
+
+// NewServer returns a server listening on addr once started.
+// Expiration times are only honoured when cache implements arc.ExpiryService, and cas
+// uniques are the versions of the cache when it implements arc.VersionService. incr and decr
+// are atomic with the other front-ends when it implements arc.UpdateService. Storage commands
+// reply NOT_STORED for the keys its admission policy rejects when it implements
+// arc.AdmissionService.
+func NewServer(addr string, cache arc.CacheService, opts ...Option) *Server {
+	s := &Server{
+		addr:    addr,
+		cache:   cache,
+		logger:  log.NewNopLogger(),
+		started: time.Now(),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.expiry, _ = cache.(arc.ExpiryService)
+	s.versions, _ = cache.(arc.VersionService)
+	s.updates, _ = cache.(arc.UpdateService)
+	s.admission, _ = cache.(arc.AdmissionService)
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the server address and serves connections until Close is called
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.connsMutex.Lock()
+	s.listener = l
+	s.connsMutex.Unlock()
+	s.logger.Info("Memcached server listening", "addr", l.Addr().String())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.closed) == 1 {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.track(conn, true)
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for their handlers to return
+func (s *Server) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	s.connsMutex.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) track(c net.Conn, add bool) {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	if add {
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer s.wg.Done()
+	defer s.track(c, false)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			if err == errLineTooLong {
+				w.WriteString("CLIENT_ERROR line too long\r\n")
+				w.Flush()
+			}
+			return
+		}
+		quit := s.dispatch(line, r, w)
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			w.Flush()
+			return
+		}
+	}
+}
+
+func (s *Server) nextCAS() uint64 {
+	return atomic.AddUint64(&s.cas, 1)
+}
@@ -0,0 +1,210 @@
+// Package metrics exposes cache statistics in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// contentType is the content type of the Prometheus text format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// reasons lists the eviction reasons always exported, so that rates start from zero
+var reasons = []arc.EvictionReason{
+	arc.EvictDemoteT1,
+	arc.EvictDemoteT2,
+	arc.EvictDropT1,
+	arc.EvictDropB1,
+	arc.EvictDropB2,
+	arc.EvictExpired,
+}
+
+// Registry holds the caches of a process, each exported with a cache label holding its name
+type Registry struct {
+	mutex  sync.RWMutex
+	caches map[string]arc.CacheService
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		caches: make(map[string]arc.CacheService),
+	}
+}
+
+// Register adds a cache under name, replacing any cache registered with the same name
+func (r *Registry) Register(name string, c arc.CacheService) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.caches[name] = c
+}
+
+// Unregister removes the cache registered under name
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.caches, name)
+}
+
+// ServeHTTP writes the metrics of every registered cache, it is meant to be mounted on /metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// sample is a single cache's value for a metric
+type sample struct {
+	labels string
+	value  interface{}
+}
+
+// family is a metric with its samples for every cache
+type family struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+func (f *family) add(value interface{}, labels ...string) {
+	f.samples = append(f.samples, sample{labels: formatLabels(labels), value: value})
+}
+
+// WriteTo writes the metrics of every registered cache to w
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	caches := make([]arc.CacheService, len(names))
+	for i, name := range names {
+		caches[i] = r.caches[name]
+	}
+	r.mutex.RUnlock()
+
+	var (
+		hits      = &family{name: "arc_hits_total", help: "Get calls served from T1 or T2.", kind: "counter"}
+		misses    = &family{name: "arc_misses_total", help: "Get calls for keys absent from the cache or only present as ghosts.", kind: "counter"}
+		ghostHits = &family{name: "arc_ghost_hits_total", help: "Accesses to keys remembered in B1 or B2.", kind: "counter"}
+		evictions = &family{name: "arc_evictions_total", help: "Entries which left a list, by reason.", kind: "counter"}
+		entries   = &family{name: "arc_entries", help: "Entries currently cached in T1 and T2.", kind: "gauge"}
+		listSize  = &family{name: "arc_list_size", help: "Entries in each of the T1, T2, B1 and B2 lists.", kind: "gauge"}
+		capacity  = &family{name: "arc_capacity", help: "Maximum number of entries cached, c.", kind: "gauge"}
+		target    = &family{name: "arc_p", help: "Adaptive target size of T1, p.", kind: "gauge"}
+		dbWrites  = &family{name: "arc_db_write_duration_seconds", help: "Latency of ghost list writes to the database.", kind: "summary"}
+		dbErrors  = &family{name: "arc_db_write_errors_total", help: "Ghost list writes to the database which failed.", kind: "counter"}
+		pinned    = &family{name: "arc_pinned_entries", help: "Entries kept from eviction by Pin.", kind: "gauge"}
+		rejected  = &family{name: "arc_admission_rejected_total", help: "New keys kept out of the cache by the admission policy.", kind: "counter"}
+		loads     = &family{name: "arc_loads_total", help: "Calls of the loader by GetOrLoad, including background reloads.", kind: "counter"}
+		loadErrs  = &family{name: "arc_load_errors_total", help: "Calls of the loader which failed.", kind: "counter"}
+		staleHits = &family{name: "arc_stale_hits_total", help: "Expired values served by GetOrLoad while they were reloaded.", kind: "counter"}
+		saves     = &family{name: "arc_store_saves_total", help: "Values saved to the backing store.", kind: "counter"}
+		storeErrs = &family{name: "arc_store_errors_total", help: "Saves and deletes of the backing store which failed.", kind: "counter"}
+		dirty     = &family{name: "arc_dirty_entries", help: "Values of write-back not saved to the backing store yet.", kind: "gauge"}
+		shadowHit = &family{name: "arc_shadow_hits_total", help: "Reads a shadow cache would have served.", kind: "counter"}
+		shadowMis = &family{name: "arc_shadow_misses_total", help: "Reads a shadow cache would have missed.", kind: "counter"}
+	)
+
+	for i, c := range caches {
+		name := names[i]
+		stats := c.Stats()
+
+		hits.add(stats.Hits, "cache", name)
+		misses.add(stats.Misses, "cache", name)
+		ghostHits.add(stats.GhostHitsB1, "cache", name, "list", "b1")
+		ghostHits.add(stats.GhostHitsB2, "cache", name, "list", "b2")
+		for _, reason := range reasons {
+			evictions.add(stats.Evictions[reason], "cache", name, "reason", string(reason))
+		}
+		entries.add(c.Len(), "cache", name)
+		listSize.add(stats.T1, "cache", name, "list", "t1")
+		listSize.add(stats.T2, "cache", name, "list", "t2")
+		listSize.add(stats.B1, "cache", name, "list", "b1")
+		listSize.add(stats.B2, "cache", name, "list", "b2")
+		capacity.add(stats.C, "cache", name)
+		target.add(stats.P, "cache", name)
+		dbWrites.samples = append(dbWrites.samples,
+			sample{labels: formatLabels([]string{"cache", name}), value: suffixed{"_sum", stats.DBWriteTime.Seconds()}},
+			sample{labels: formatLabels([]string{"cache", name}), value: suffixed{"_count", stats.DBWrites}},
+		)
+		dbErrors.add(stats.DBErrors, "cache", name)
+		pinned.add(stats.Pinned, "cache", name)
+		rejected.add(stats.Rejected, "cache", name)
+		loads.add(stats.Loads, "cache", name)
+		loadErrs.add(stats.LoadErrors, "cache", name)
+		staleHits.add(stats.StaleHits, "cache", name)
+		saves.add(stats.StoreSaves, "cache", name)
+		storeErrs.add(stats.StoreErrors, "cache", name)
+		dirty.add(stats.Dirty, "cache", name)
+		for _, s := range stats.Shadows {
+			shadowHit.add(s.Hits, "cache", name, "shadow", s.Name)
+			shadowMis.add(s.Misses, "cache", name, "shadow", s.Name)
+		}
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range []*family{hits, misses, ghostHits, evictions, entries, listSize, capacity, target, dbWrites, dbErrors, pinned, rejected, loads, loadErrs, staleHits, saves, storeErrs, dirty, shadowHit, shadowMis} {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", f.name, f.kind)
+		for _, s := range f.samples {
+			if v, ok := s.value.(suffixed); ok {
+				fmt.Fprintf(cw, "%s%s%s %v\n", f.name, v.suffix, s.labels, v.value)
+				continue
+			}
+			fmt.Fprintf(cw, "%s%s %v\n", f.name, s.labels, s.value)
+		}
+	}
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+// suffixed is a sample of a summary, written as name_sum or name_count
+type suffixed struct {
+	suffix string
+	value  interface{}
+}
+
+// formatLabels formats label name and value pairs as {name="value",...}
+func formatLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escaper.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
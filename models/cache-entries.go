@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// EntryStore keeps the values of a cache in the cache_entries table, to back it with arc.SetStore
+type EntryStore struct {
+	database *Database
+}
+
+var _ arc.Store = (*EntryStore)(nil)
+
+// NewEntryStore returns a store of cache entries
+func NewEntryStore(db *Database) *EntryStore {
+	return &EntryStore{database: db}
+}
+
+// Load returns the value stored for key as a string, arc.ErrNotFound when there is none
+func (s *EntryStore) Load(key interface{}) (interface{}, error) {
+	s.database.logger.Debug("Loading a cache entry from database.", "key", fmt.Sprint(key))
+	var value []byte
+	err := s.database.db.QueryRow("SELECT `entry_value` FROM `cache_entries` WHERE `entry_key` = ?", fmt.Sprint(key)).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, arc.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error loading cache entry %s", err)
+	}
+	return string(value), nil
+}
+
+// Save inserts or replaces the value of key, values other than strings and byte slices are printed with fmt
+func (s *EntryStore) Save(key, value interface{}) error {
+	s.database.logger.Debug("Saving a cache entry in database.", "key", fmt.Sprint(key))
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		b = []byte(fmt.Sprint(v))
+	}
+	_, err := s.database.db.Exec("INSERT INTO `cache_entries` (`entry_key`, `entry_value`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `entry_value` = VALUES(`entry_value`);", fmt.Sprint(key), b)
+	if err != nil {
+		return fmt.Errorf("Error saving cache entry %s", err)
+	}
+	return nil
+}
+
+// Delete removes key, deleting a key which is not stored is not an error
+func (s *EntryStore) Delete(key interface{}) error {
+	s.database.logger.Debug("Deleting a cache entry from database.", "key", fmt.Sprint(key))
+	if _, err := s.database.db.Exec("DELETE FROM `cache_entries` WHERE `entry_key` = ?", fmt.Sprint(key)); err != nil {
+		return fmt.Errorf("Error deleting cache entry %s", err)
+	}
+	return nil
+}
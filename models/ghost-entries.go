@@ -2,6 +2,9 @@ package models
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/deepak11627/arc/arc"
 )
 
 /*
@@ -154,6 +157,39 @@ func (gl *GhostList) Remove(listID string) error {
 	return err
 }
 
+// Batch applies the writes of a batch in one transaction, inserting consecutive entries with a single statement
+func (gl *GhostList) Batch(ops []arc.DBOp) error {
+	logger := gl.database.logger
+	logger.Debug("Applying a batch of ghost list writes to database.", "ops", len(ops))
+
+	tx, err := gl.database.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error starting ghost entries batch %s", err)
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].Remove {
+			_, err = tx.Exec("DELETE FROM `ghost_lists` WHERE `list_id` = ? Limit 1", ops[i].ListID)
+			i++
+		} else {
+			j := i
+			var rows []string
+			var args []interface{}
+			for ; j < len(ops) && !ops[j].Remove; j++ {
+				rows = append(rows, "(?, ?, ?)")
+				args = append(args, ops[j].ListID, ops[j].Key, ops[j].Value)
+			}
+			_, err = tx.Exec("INSERT INTO `ghost_lists` (`list_id`, `ghost_key`, `ghost_value`) VALUES "+strings.Join(rows, ", ")+" ON DUPLICATE KEY UPDATE `ghost_value` = VALUES(`ghost_value`);", args...)
+			i = j
+		}
+		if err != nil {
+			tx.Rollback()
+			logger.Debug(fmt.Sprintf("Error writing ghost entries batch: %s", err))
+			return fmt.Errorf("Error writing ghost entries batch %s", err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (gl *GhostList) Reset() error {
 	logger := gl.database.logger
 	logger.Debug("Deleting lists from Database.")
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// LoadCache fills the cache with the content saved to path, a missing file leaves it empty
+func LoadCache(a arc.CacheService, path string) error {
+	p, ok := a.(arc.PersistService)
+	if !ok {
+		return fmt.Errorf("the cache cannot be loaded")
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.Load(f)
+}
+
+// SaveCache writes the content of the cache to path. It writes to a temporary file renamed
+// over path once complete, so a crash while saving keeps the previous save.
+func SaveCache(a arc.CacheService, path string) error {
+	p, ok := a.(arc.PersistService)
+	if !ok {
+		return fmt.Errorf("the cache cannot be saved")
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := p.Save(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// OpenWAL recovers the cache from the write-ahead log in dir and starts recording to it.
+// The returned function closes the log.
+func OpenWAL(a arc.CacheService, dir, sync string) (func(), error) {
+	w, ok := a.(arc.WALService)
+	if !ok {
+		return nil, fmt.Errorf("the cache does not support a write-ahead log")
+	}
+	cfg := arc.WALConfig{Dir: dir}
+	switch sync {
+	case "always":
+		cfg.Sync = arc.SyncAlways
+	case "interval":
+		cfg.Sync = arc.SyncInterval
+	case "never":
+		cfg.Sync = arc.SyncNever
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q, use always, interval or never", sync)
+	}
+	if err := w.OpenWAL(cfg); err != nil {
+		return nil, err
+	}
+	return func() { w.Close() }, nil
+}
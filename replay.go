@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/logreplay"
+	"github.com/deepak11627/arc/utils"
+)
+
+// Replay rebuilds the cache from its debug log and prints its lists at the chosen time,
+// with the history of a key to explain where it went
+func Replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	logFile := fs.String("log", "out.log", "Debug log of the cache to replay.")
+	from := fs.String("from", "", "Time the run of the cache to replay started at, in the layout of the log. The log is replayed from its start when empty.")
+	until := fs.String("until", "", "Time to stop the replay at, in the layout of the log e.g. 2018-04-08T01:25:13.259+0530. The whole log is replayed when empty.")
+	key := fs.String("key", "", "Key to print the history of.")
+	verbose := fs.Bool("v", false, "Print every operation replayed.")
+	fs.Parse(args)
+
+	if CacheSize <= 0 {
+		return errors.New("the size flag must be set to the size of the cache which wrote the log")
+	}
+	var t time.Time
+	if *until != "" {
+		var err error
+		if t, err = time.Parse(logreplay.TimeLayout, *until); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(*logFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ops, err := logreplay.Parse(f)
+	if err != nil {
+		return err
+	}
+	if *from != "" {
+		start, err := time.Parse(logreplay.TimeLayout, *from)
+		if err != nil {
+			return err
+		}
+		for len(ops) > 0 && ops[0].Time.Before(start) {
+			ops = ops[1:]
+		}
+	}
+	state := logreplay.Rebuild(ops, CacheSize, t)
+
+	if *verbose {
+		utils.RenderMessageHeading("Operations replayed.")
+		fmt.Println()
+		for _, op := range ops[:state.Applied] {
+			fmt.Printf("%s %s %s %s\n", op.Time.Format(logreplay.TimeLayout), op.Op, op.Key, op.Value)
+		}
+		utils.RenderMessageEnd()
+	}
+
+	utils.RenderMessageHeading(fmt.Sprintf("Cache after %d operations, at %s.", state.Applied, state.Time.Format(logreplay.TimeLayout)))
+	fmt.Printf("\nc %d, p %d\n", state.Snapshot.C, state.Snapshot.P)
+	for _, l := range []struct {
+		name    string
+		entries []arc.SnapshotEntry
+	}{{"T1", state.Snapshot.T1}, {"T2", state.Snapshot.T2}, {"B1", state.Snapshot.B1}, {"B2", state.Snapshot.B2}} {
+		fmt.Printf("%s:", l.name)
+		for _, e := range l.entries {
+			fmt.Printf(" %v", e.Key)
+		}
+		fmt.Println()
+	}
+	utils.RenderMessageEnd()
+
+	if *key != "" {
+		utils.RenderMessageHeading(fmt.Sprintf("History of %s.", *key))
+		fmt.Println()
+		steps := state.History[*key]
+		if len(steps) == 0 {
+			fmt.Println("The key was never inserted.")
+		}
+		for _, s := range steps {
+			fmt.Println(s)
+		}
+		utils.RenderMessageEnd()
+	}
+
+	if len(state.Mismatches) > 0 {
+		utils.RenderMessageHeading("The replay diverged from the log, check the size flag and that the log holds a single run.")
+		fmt.Println()
+		for _, m := range state.Mismatches {
+			fmt.Println(m)
+		}
+		utils.RenderMessageEnd()
+	}
+	return nil
+}
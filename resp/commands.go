@@ -0,0 +1,747 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+const version = "1.0.0-arc"
+
+// dispatch runs a single command, it reports whether the connection should be closed
+func (s *Server) dispatch(args [][]byte, w *writer) bool {
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	switch name {
+	case "PING":
+		s.ping(args, w)
+	case "HELLO":
+		s.hello(args, w)
+	case "GET":
+		if arity(name, args, 1, 1, w) {
+			s.get(args[0], w)
+		}
+	case "SET":
+		s.set(args, w)
+	case "DEL":
+		if arity(name, args, 1, -1, w) {
+			s.del(args, w)
+		}
+	case "EXISTS":
+		if arity(name, args, 1, -1, w) {
+			s.exists(args, w)
+		}
+	case "MGET":
+		if arity(name, args, 1, -1, w) {
+			s.mget(args, w)
+		}
+	case "MSET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			w.error("ERR wrong number of arguments for 'mset' command")
+		} else {
+			s.mset(args, w)
+		}
+	case "EXPIRE", "PEXPIRE":
+		if arity(name, args, 2, 2, w) {
+			s.expire(args[0], args[1], name == "PEXPIRE", w)
+		}
+	case "PERSIST":
+		if arity(name, args, 1, 1, w) {
+			s.persist(args[0], w)
+		}
+	case "INCR", "DECR":
+		if arity(name, args, 1, 1, w) {
+			s.incr(args[0], []byte("1"), name == "DECR", w)
+		}
+	case "INCRBY", "DECRBY":
+		if arity(name, args, 2, 2, w) {
+			s.incr(args[0], args[1], name == "DECRBY", w)
+		}
+	case "INCRBYFLOAT":
+		if arity(name, args, 2, 2, w) {
+			s.incrByFloat(args[0], args[1], w)
+		}
+	case "ARC.SNAPSHOT":
+		s.snapshot(w)
+	case "ARC.GETS":
+		if arity(name, args, 1, 1, w) {
+			s.gets(args[0], w)
+		}
+	case "ARC.CAS":
+		if arity(name, args, 3, 3, w) {
+			s.cas(args[0], args[1], args[2], w)
+		}
+	case "ARC.VERSION":
+		if arity(name, args, 1, 1, w) {
+			s.version(args[0], w)
+		}
+	case "ARC.PIN":
+		if arity(name, args, 1, 1, w) {
+			s.pin(args[0], w)
+		}
+	case "ARC.UNPIN":
+		if arity(name, args, 1, 1, w) {
+			s.unpin(args[0], w)
+		}
+	case "ARC.SETTAGS":
+		if arity(name, args, 3, -1, w) {
+			s.setTags(args, w)
+		}
+	case "ARC.INVALIDATETAG", "ARC.INVALIDATEPREFIX":
+		if arity(name, args, 1, 1, w) {
+			s.invalidate(args[0], name == "ARC.INVALIDATEPREFIX", w)
+		}
+	case "SCAN":
+		if arity(name, args, 1, 5, w) {
+			s.scan(args, w)
+		}
+	case "TTL", "PTTL":
+		if arity(name, args, 1, 1, w) {
+			s.ttl(args[0], name == "PTTL", w)
+		}
+	case "DBSIZE":
+		w.integer(int64(s.cache.Len()))
+	case "FLUSHDB", "FLUSHALL":
+		s.cache.Purge()
+		w.simple("OK")
+	case "INFO":
+		s.info(w)
+	case "SELECT":
+		if arity(name, args, 1, 1, w) {
+			if string(args[0]) == "0" {
+				w.simple("OK")
+			} else {
+				w.error("ERR DB index is out of range")
+			}
+		}
+	case "COMMAND":
+		// redis-cli asks for command docs on start up, an empty reply is enough
+		w.array(0)
+	case "QUIT":
+		w.simple("OK")
+		return true
+	default:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+	}
+	return false
+}
+
+// arity checks the number of arguments of a command, max is -1 when unbounded
+func arity(name string, args [][]byte, min, max int, w *writer) bool {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+	return true
+}
+
+func (s *Server) ping(args [][]byte, w *writer) {
+	switch len(args) {
+	case 0:
+		w.simple("PONG")
+	case 1:
+		w.bulk(args[0])
+	default:
+		w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// hello switches the connection between RESP2 and RESP3 and describes the server
+func (s *Server) hello(args [][]byte, w *writer) {
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if proto != 2 && proto != 3 {
+			w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		w.proto = proto
+	}
+	w.mapHeader(4)
+	w.bulk([]byte("server"))
+	w.bulk([]byte("arc"))
+	w.bulk([]byte("version"))
+	w.bulk([]byte(version))
+	w.bulk([]byte("proto"))
+	w.integer(int64(w.proto))
+	w.bulk([]byte("mode"))
+	w.bulk([]byte("standalone"))
+}
+
+func (s *Server) get(key []byte, w *writer) {
+	if s.loader != nil {
+		v, err := s.loader.GetOrLoad(string(key))
+		switch {
+		case err == arc.ErrNoLoader:
+		case err == arc.ErrNotFound:
+			w.null()
+			return
+		case err != nil:
+			w.error("ERR unable to load the key")
+			return
+		default:
+			w.bulk(toBytes(v))
+			return
+		}
+	}
+	v, ok := s.cache.Get(string(key))
+	if !ok {
+		w.null()
+		return
+	}
+	w.bulk(toBytes(v))
+}
+
+// set implements SET key value [EX seconds|PX milliseconds] [NX|XX] [GET]
+func (s *Server) set(args [][]byte, w *writer) {
+	if len(args) < 2 {
+		w.error("ERR wrong number of arguments for 'set' command")
+		return
+	}
+	key, value := string(args[0]), args[1]
+
+	var ttl time.Duration
+	var nx, xx, get bool
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "NX" && !xx:
+			nx = true
+		case opt == "XX" && !nx:
+			xx = true
+		case opt == "GET":
+			get = true
+		case (opt == "EX" || opt == "PX") && ttl == 0 && i+1 < len(args):
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+	if ttl > 0 && s.expiry == nil {
+		w.error("ERR expiration is not supported by this cache")
+		return
+	}
+
+	if nx && !get && s.admission != nil {
+		switch stored, err := s.admission.TryPutIfAbsent(key, value, ttl); {
+		case err == arc.ErrRejected || (err == nil && !stored):
+			w.null()
+		case err != nil:
+			w.error("ERR " + err.Error())
+		default:
+			w.simple("OK")
+		}
+		return
+	}
+	if nx && !get && s.versions != nil {
+		if !s.versions.PutIfAbsent(key, value) {
+			w.null()
+			return
+		}
+		if ttl > 0 {
+			s.expiry.Touch(key, ttl)
+		}
+		w.simple("OK")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var old []byte
+	exists := false
+	if get {
+		var v interface{}
+		if v, exists = s.cache.Get(key); exists {
+			old = toBytes(v)
+		}
+	} else if nx || xx {
+		exists = s.has(key)
+	}
+	if (nx && exists) || (xx && !exists) {
+		s.reply(get, old, exists, w)
+		return
+	}
+	s.put(key, value, ttl)
+	if get {
+		s.reply(get, old, exists, w)
+		return
+	}
+	w.simple("OK")
+}
+
+// reply answers a SET which was not applied, or which asked for the previous value with GET
+func (s *Server) reply(get bool, old []byte, exists bool, w *writer) {
+	if get && exists {
+		w.bulk(old)
+		return
+	}
+	w.null()
+}
+
+func (s *Server) expire(key, n []byte, millis bool, w *writer) {
+	if s.expiry == nil {
+		w.error("ERR expiration is not supported by this cache")
+		return
+	}
+	d, err := strconv.ParseInt(string(n), 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	unit := time.Second
+	if millis {
+		unit = time.Millisecond
+	}
+	if d <= 0 {
+		// like Redis, a non positive timeout deletes the key
+		if s.cache.Delete(string(key)) {
+			w.integer(1)
+		} else {
+			w.integer(0)
+		}
+		return
+	}
+	if s.expiry.Touch(string(key), time.Duration(d)*unit) {
+		w.integer(1)
+	} else {
+		w.integer(0)
+	}
+}
+
+func (s *Server) persist(key []byte, w *writer) {
+	if s.expiry == nil {
+		w.integer(0)
+		return
+	}
+	left, ok := s.expiry.TTL(string(key))
+	if !ok || left == 0 {
+		w.integer(0)
+		return
+	}
+	s.expiry.Touch(string(key), 0)
+	w.integer(1)
+}
+
+// snapshot replies with the content of T1, T2, B1 and B2 encoded as JSON, like the HTTP /snapshot endpoint
+func (s *Server) snapshot(w *writer) {
+	snap := s.cache.Snapshot()
+	for _, l := range [][]arc.SnapshotEntry{snap.T1, snap.T2, snap.B1, snap.B2} {
+		// values written over RESP are bytes, which JSON would encode in base64
+		for i := range l {
+			if b, ok := l[i].Value.([]byte); ok {
+				l[i].Value = string(b)
+			}
+		}
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.bulk(b)
+}
+
+// gets replies with the value of key and its version, or null
+func (s *Server) gets(key []byte, w *writer) {
+	if s.versions == nil {
+		w.error("ERR versions are not supported by this cache")
+		return
+	}
+	v, version, ok := s.versions.GetWithVersion(string(key))
+	if !ok {
+		w.null()
+		return
+	}
+	w.array(2)
+	w.bulk(toBytes(v))
+	w.integer(int64(version))
+}
+
+// cas stores value at key if its version did not change. It replies with the new version,
+// 0 when the version changed, null when the key is not cached, or an error when the store
+// failed to save the value.
+func (s *Server) cas(key, version, value []byte, w *writer) {
+	if s.versions == nil {
+		w.error("ERR versions are not supported by this cache")
+		return
+	}
+	v, err := strconv.ParseUint(string(version), 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	switch version, err := s.versions.CompareAndSwap(string(key), v, value); err {
+	case nil:
+		w.integer(int64(version))
+	case arc.ErrVersionMismatch:
+		w.integer(0)
+	case arc.ErrNotFound:
+		w.null()
+	default:
+		w.error("ERR " + err.Error())
+	}
+}
+
+// version replies with the version of the value of key, or null
+func (s *Server) version(key []byte, w *writer) {
+	if s.versions == nil {
+		w.error("ERR versions are not supported by this cache")
+		return
+	}
+	version, ok := s.versions.Version(string(key))
+	if !ok {
+		w.null()
+		return
+	}
+	w.integer(int64(version))
+}
+
+// incr adds or subtracts delta to the integer at key and replies with the result
+func (s *Server) incr(key, delta []byte, decr bool, w *writer) {
+	if s.counters == nil {
+		w.error("ERR counters are not supported by this cache")
+		return
+	}
+	d, err := strconv.ParseInt(string(delta), 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+	var n int64
+	if decr {
+		n, err = s.counters.Decrement(string(key), d)
+	} else {
+		n, err = s.counters.Increment(string(key), d)
+	}
+	switch err {
+	case nil:
+		w.integer(n)
+	case arc.ErrOverflow:
+		w.error("ERR increment or decrement would overflow")
+	case arc.ErrNotNumber:
+		w.error("ERR value is not an integer or out of range")
+	default:
+		w.error("ERR " + err.Error())
+	}
+}
+
+// incrByFloat adds delta to the number at key and replies with the result as a bulk string
+func (s *Server) incrByFloat(key, delta []byte, w *writer) {
+	if s.counters == nil {
+		w.error("ERR counters are not supported by this cache")
+		return
+	}
+	d, err := strconv.ParseFloat(string(delta), 64)
+	if err != nil || math.IsNaN(d) || math.IsInf(d, 0) {
+		w.error("ERR value is not a valid float")
+		return
+	}
+	f, err := s.counters.IncrementFloat(string(key), d)
+	switch {
+	case err == arc.ErrNotNumber:
+		w.error("ERR increment would produce NaN or Infinity or value is not a valid float")
+		return
+	case err != nil:
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.bulk([]byte(strconv.FormatFloat(f, 'f', -1, 64)))
+}
+
+// pin keeps key from being evicted, it replies OK, or null when the key is not cached
+func (s *Server) pin(key []byte, w *writer) {
+	if s.pins == nil {
+		w.error("ERR pins are not supported by this cache")
+		return
+	}
+	switch s.pins.Pin(string(key)) {
+	case nil:
+		w.simple("OK")
+	case arc.ErrPinLimit:
+		w.error("ERR pinned capacity reached")
+	default:
+		w.null()
+	}
+}
+
+// unpin replies 1 when key was pinned, 0 otherwise
+func (s *Server) unpin(key []byte, w *writer) {
+	if s.pins == nil {
+		w.error("ERR pins are not supported by this cache")
+		return
+	}
+	if s.pins.Unpin(string(key)) {
+		w.integer(1)
+	} else {
+		w.integer(0)
+	}
+}
+
+// setTags implements ARC.SETTAGS key value milliseconds [tag ...], 0 milliseconds never
+// expiring. It replies 1 when the key already held a value, 0 otherwise.
+func (s *Server) setTags(args [][]byte, w *writer) {
+	if s.tags == nil {
+		w.error("ERR tags are not supported by this cache")
+		return
+	}
+	ms, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil || ms < 0 {
+		w.error("ERR invalid expire time in 'arc.settags' command")
+		return
+	}
+	tags := make([]string, 0, len(args)-3)
+	for _, t := range args[3:] {
+		tags = append(tags, string(t))
+	}
+	if s.tags.PutWithTags(string(args[0]), args[1], time.Duration(ms)*time.Millisecond, tags...) {
+		w.integer(1)
+	} else {
+		w.integer(0)
+	}
+}
+
+// invalidate removes the keys with a tag, or starting with a prefix, and replies how many
+func (s *Server) invalidate(match []byte, prefix bool, w *writer) {
+	if s.tags == nil {
+		w.error("ERR tags are not supported by this cache")
+		return
+	}
+	if prefix {
+		w.integer(int64(s.tags.InvalidatePrefix(string(match))))
+	} else {
+		w.integer(int64(s.tags.InvalidateTag(string(match))))
+	}
+}
+
+// scan replies the next cursor and a page of keys, like Redis SCAN cursor [MATCH pattern] [COUNT count]
+func (s *Server) scan(args [][]byte, w *writer) {
+	if s.scanner == nil {
+		w.error("ERR scanning is not supported by this cache")
+		return
+	}
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return
+	}
+	var match string
+	var count int
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			match = string(args[i+1])
+		case "COUNT":
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count <= 0 {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+	keys, next := s.scanner.Scan(cursor, match, count)
+	w.array(2)
+	w.bulk([]byte(strconv.FormatUint(next, 10)))
+	w.array(len(keys))
+	for _, key := range keys {
+		w.bulk(toBytes(key))
+	}
+}
+
+func (s *Server) del(keys [][]byte, w *writer) {
+	var n int64
+	for _, key := range keys {
+		if s.cache.Delete(string(key)) {
+			n++
+		}
+	}
+	w.integer(n)
+}
+
+func (s *Server) exists(keys [][]byte, w *writer) {
+	var n int64
+	for _, key := range keys {
+		if s.has(string(key)) {
+			n++
+		}
+	}
+	w.integer(n)
+}
+
+func (s *Server) mget(keys [][]byte, w *writer) {
+	if s.batch == nil {
+		w.array(len(keys))
+		for _, key := range keys {
+			s.get(key, w)
+		}
+		return
+	}
+	batch := make([]interface{}, len(keys))
+	for i, key := range keys {
+		batch[i] = string(key)
+	}
+	values := s.batch.GetMany(batch)
+	w.array(len(keys))
+	for _, key := range batch {
+		if v, ok := values[key]; ok {
+			w.bulk(toBytes(v))
+		} else {
+			w.null()
+		}
+	}
+}
+
+func (s *Server) mset(args [][]byte, w *writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.batch != nil {
+		entries := make(map[interface{}]interface{}, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			entries[string(args[i])] = args[i+1]
+		}
+		if _, err := s.batch.PutMany(entries); err != nil {
+			w.error("ERR " + failedKeys(err))
+			return
+		}
+		w.simple("OK")
+		return
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.put(string(args[i]), args[i+1], 0)
+	}
+	w.simple("OK")
+}
+
+// failedKeys names the keys of a *arc.StoreError, sorted, after its message. MSET is not
+// atomic, the other keys were stored.
+func failedKeys(err error) string {
+	serr, ok := err.(*arc.StoreError)
+	if !ok {
+		return err.Error()
+	}
+	keys := make([]string, 0, len(serr.Errors))
+	for key := range serr.Errors {
+		keys = append(keys, fmt.Sprint(key))
+	}
+	sort.Strings(keys)
+	return serr.Error() + ": " + strings.Join(keys, " ")
+}
+
+// ttl replies -2 for missing keys, -1 for keys without expiry and the time left otherwise
+func (s *Server) ttl(key []byte, millis bool, w *writer) {
+	if s.expiry == nil {
+		if s.has(string(key)) {
+			w.integer(-1)
+		} else {
+			w.integer(-2)
+		}
+		return
+	}
+	left, ok := s.expiry.TTL(string(key))
+	switch {
+	case !ok:
+		w.integer(-2)
+	case left == 0:
+		w.integer(-1)
+	case millis:
+		w.integer(int64((left + time.Millisecond/2) / time.Millisecond))
+	default:
+		w.integer(int64((left + time.Second/2) / time.Second))
+	}
+}
+
+// info describes the server and the shape of the ARC in the INFO format
+func (s *Server) info(w *writer) {
+	stats := s.cache.Stats()
+	s.connsMutex.Lock()
+	conns := len(s.conns)
+	s.connsMutex.Unlock()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Server\r\n")
+	fmt.Fprintf(&b, "redis_version:%s\r\n", version)
+	fmt.Fprintf(&b, "process_id:%d\r\n", os.Getpid())
+	fmt.Fprintf(&b, "uptime_in_seconds:%d\r\n", int64(time.Since(s.started).Seconds()))
+	fmt.Fprintf(&b, "\r\n# Clients\r\n")
+	fmt.Fprintf(&b, "connected_clients:%d\r\n", conns)
+	fmt.Fprintf(&b, "\r\n# Stats\r\n")
+	fmt.Fprintf(&b, "total_commands_processed:%d\r\n", atomic.LoadUint64(&s.commands))
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(&b, "\r\n# ARC\r\n")
+	fmt.Fprintf(&b, "arc_c:%d\r\n", stats.C)
+	fmt.Fprintf(&b, "arc_p:%d\r\n", stats.P)
+	fmt.Fprintf(&b, "arc_t1:%d\r\n", stats.T1)
+	fmt.Fprintf(&b, "arc_t2:%d\r\n", stats.T2)
+	fmt.Fprintf(&b, "arc_b1:%d\r\n", stats.B1)
+	fmt.Fprintf(&b, "arc_b2:%d\r\n", stats.B2)
+	fmt.Fprintf(&b, "arc_hit_ratio:%.4f\r\n", stats.HitRatio())
+	fmt.Fprintf(&b, "arc_pinned:%d\r\n", stats.Pinned)
+	fmt.Fprintf(&b, "arc_rejected:%d\r\n", stats.Rejected)
+	for _, sh := range stats.Shadows {
+		fmt.Fprintf(&b, "arc_shadow:name=%s,capacity=%d,hits=%d,misses=%d\r\n", sh.Name, sh.Capacity, sh.Hits, sh.Misses)
+	}
+	fmt.Fprintf(&b, "\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:keys=%d\r\n", s.cache.Len())
+	w.bulk(b.Bytes())
+}
+
+// has reports whether key is cached, without affecting its position when the cache supports expiry
+func (s *Server) has(key string) bool {
+	if s.expiry != nil {
+		_, ok := s.expiry.TTL(key)
+		return ok
+	}
+	_, ok := s.cache.Get(key)
+	return ok
+}
+
+func (s *Server) put(key string, value []byte, ttl time.Duration) {
+	if s.expiry != nil {
+		s.expiry.PutWithTTL(key, value, ttl)
+		return
+	}
+	s.cache.Put(key, value)
+}
+
+// toBytes presents values stored by other front-ends as bulk strings
+func toBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,167 @@
+package resp
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// step sends a request and expects exactly the reply want
+type step struct {
+	send string
+	want string
+}
+
+var errUnavailable = errors.New("store unavailable")
+
+// flakyStore holds nothing and fails every save while failing is set
+type flakyStore struct {
+	failing int32
+}
+
+func (s *flakyStore) Load(key interface{}) (interface{}, error) {
+	return nil, arc.ErrNotFound
+}
+
+func (s *flakyStore) Save(key, value interface{}) error {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		return errUnavailable
+	}
+	return nil
+}
+
+func (s *flakyStore) Delete(key interface{}) error {
+	return nil
+}
+
+func (s *flakyStore) fail(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&s.failing, v)
+}
+
+// badStore fails to save the keys starting with bad
+type badStore struct {
+	flakyStore
+}
+
+func (s *badStore) Save(key, value interface{}) error {
+	if strings.HasPrefix(key.(string), "bad") {
+		return errUnavailable
+	}
+	return nil
+}
+
+func newCache(c int, opts ...arc.Option) arc.CacheService {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	return arc.NewARC(c, list.New(), list.New(), list.New(), list.New(), opts...)
+}
+
+// dial serves cache to one end of an in-process connection and returns the other
+func dial(t *testing.T, cache arc.CacheService) net.Conn {
+	s := NewServer("", cache)
+	client, conn := net.Pipe()
+	s.track(conn, true)
+	s.wg.Add(1)
+	go s.serveConn(conn)
+	t.Cleanup(func() {
+		client.Close()
+		s.Close()
+	})
+	return client
+}
+
+// command encodes args as a RESP array
+func command(args ...string) string {
+	cmd := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, a := range args {
+		cmd += "$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n"
+	}
+	return cmd
+}
+
+func run(t *testing.T, c net.Conn, steps []step) {
+	t.Helper()
+	for _, st := range steps {
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		go c.Write([]byte(st.send))
+		got := make([]byte, len(st.want))
+		if _, err := io.ReadFull(c, got); err != nil {
+			t.Fatalf("%q: reading the reply: %v, got %q", st.send, err, got)
+		}
+		if string(got) != st.want {
+			t.Fatalf("%q: got %q, want %q", st.send, got, st.want)
+		}
+	}
+}
+
+func TestCASStoreError(t *testing.T) {
+	store := &flakyStore{}
+	cache := newCache(10, arc.SetStore(store, arc.WriteThrough))
+	c := dial(t, cache)
+	run(t, c, []step{{command("SET", "k", "a"), "+OK\r\n"}})
+
+	version, _ := cache.(arc.VersionService).Version("k")
+	store.fail(true)
+	run(t, c, []step{
+		{command("ARC.CAS", "k", strconv.FormatUint(version, 10), "b"), "-ERR " + errUnavailable.Error() + "\r\n"},
+		{command("ARC.CAS", "missing", "1", "b"), "$-1\r\n"},
+		{command("GET", "k"), "$1\r\na\r\n"},
+	})
+}
+
+func TestIncrStoreError(t *testing.T) {
+	store := &flakyStore{}
+	c := dial(t, newCache(10, arc.SetStore(store, arc.WriteThrough)))
+	run(t, c, []step{
+		{command("SET", "s", "a"), "+OK\r\n"},
+		{command("INCR", "s"), "-ERR value is not an integer or out of range\r\n"},
+		{command("INCRBYFLOAT", "s", "1.5"), "-ERR increment would produce NaN or Infinity or value is not a valid float\r\n"},
+	})
+	store.fail(true)
+	run(t, c, []step{
+		{command("INCR", "n"), "-ERR " + errUnavailable.Error() + "\r\n"},
+		{command("INCRBYFLOAT", "n", "1.5"), "-ERR " + errUnavailable.Error() + "\r\n"},
+		{command("EXISTS", "n"), ":0\r\n"},
+	})
+}
+
+func TestSetNX(t *testing.T) {
+	cache := newCache(2, arc.SetAdmission(true))
+	c := dial(t, cache)
+	run(t, c, []step{
+		{command("SET", "a", "a", "NX", "EX", "100"), "+OK\r\n"},
+		{command("SET", "a", "b", "NX"), "$-1\r\n"},
+		{command("SET", "b", "b"), "+OK\r\n"},
+	})
+	ttl, ok := cache.(arc.ExpiryService).TTL("a")
+	if !ok || ttl <= 90*time.Second || ttl > 100*time.Second {
+		t.Fatalf("got a ttl of %v, want about 100s", ttl)
+	}
+	for i := 0; i < 5; i++ {
+		run(t, c, []step{{command("MGET", "a", "b"), "*2\r\n$1\r\na\r\n$1\r\nb\r\n"}})
+	}
+	run(t, c, []step{
+		{command("SET", "c", "c", "NX"), "$-1\r\n"},
+		{command("GET", "c"), "$-1\r\n"},
+	})
+}
+
+func TestMSetStoreError(t *testing.T) {
+	c := dial(t, newCache(10, arc.SetStore(&badStore{}, arc.WriteThrough)))
+	run(t, c, []step{
+		{command("MSET", "a", "1", "bad2", "x", "bad1", "y"), "-ERR arc: the store failed to save 2 keys: bad1 bad2\r\n"},
+		{command("MGET", "a", "bad1", "bad2"), "*3\r\n$1\r\n1\r\n$-1\r\n$-1\r\n"},
+	})
+}
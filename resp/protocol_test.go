@@ -0,0 +1,61 @@
+package resp
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		args []string
+		err  string
+	}{
+		{name: "array", in: "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", args: []string{"GET", "k"}},
+		{name: "inline", in: "GET k\r\n", args: []string{"GET", "k"}},
+		{name: "empty line", in: "\r\n"},
+		{name: "empty array", in: "*0\r\n"},
+		{name: "null array", in: "*-1\r\n"},
+		{name: "negative array", in: "*-5\r\n"},
+		{name: "bad array length", in: "*x\r\n", err: "invalid multibulk length"},
+		{name: "array too long", in: "*2000000\r\n", err: "invalid multibulk length"},
+		{name: "missing dollar", in: "*1\r\n:1\r\n", err: "expected '$'"},
+		{name: "negative bulk length", in: "*1\r\n$-1\r\n", err: "invalid bulk length"},
+		{name: "unterminated bulk", in: "*1\r\n$1\r\nkx\r\n", err: "not terminated by CRLF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := readCommand(bufio.NewReader(strings.NewReader(tt.in)))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, a := range args {
+				got = append(got, string(a))
+			}
+			if !reflect.DeepEqual(got, tt.args) {
+				t.Fatalf("got %q, want %q", got, tt.args)
+			}
+		})
+	}
+}
+
+func TestReadCommandAfterNullArray(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*-1\r\n*1\r\n$4\r\nPING\r\n"))
+	if args, err := readCommand(r); err != nil || len(args) != 0 {
+		t.Fatalf("got %q, %v, want an empty command", args, err)
+	}
+	args, err := readCommand(r)
+	if err != nil || len(args) != 1 || string(args[0]) != "PING" {
+		t.Fatalf("got %q, %v, want PING", args, err)
+	}
+}
@@ -0,0 +1,176 @@
+// Package resp serves a cache over the Redis serialization protocol (RESP2 and RESP3)
+package resp
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// Server speaks a subset of the Redis protocol on top of a cache
+type Server struct {
+	addr      string
+	cache     arc.CacheService
+	expiry    arc.ExpiryService
+	versions  arc.VersionService
+	counters  arc.CounterService
+	batch     arc.BatchService
+	pins      arc.PinService
+	tags      arc.TagService
+	scanner   arc.ScanService
+	loader    arc.LoadService
+	admission arc.AdmissionService
+	logger    arc.Logger
+	started   time.Time
+	listener  net.Listener
+
+	// mutex makes the conditional writes (SET XX, and NX for caches without versions) atomic
+	mutex sync.Mutex
+
+	connsMutex sync.Mutex
+	conns      map[net.Conn]struct{}
+	wg         sync.WaitGroup
+	closed     int32
+
+	commands uint64
+}
+
+// Option type setting params dynamically
+type Option func(*Server)
+
+// SetLogger function to set logger dynamically
+func SetLogger(l arc.Logger) func(*Server) {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// NewServer returns a server listening on addr once started.
+// Time to live options are only honoured when cache implements arc.ExpiryService,
+// the ARC.GETS, ARC.CAS and ARC.VERSION commands need an arc.VersionService and
+// INCR, DECR and their variants an arc.CounterService, ARC.PIN and ARC.UNPIN an
+// arc.PinService, ARC.SETTAGS and the ARC.INVALIDATE commands an arc.TagService and SCAN
+// an arc.ScanService. GET loads the keys it misses through an arc.LoadService with a loader
+// or a store. MGET and MSET take the lock of the cache once when it implements arc.BatchService.
+// SET NX sets the value and its time to live at once, and replies null for the keys the
+// admission policy rejects, when it implements arc.AdmissionService.
+func NewServer(addr string, cache arc.CacheService, opts ...Option) *Server {
+	s := &Server{
+		addr:    addr,
+		cache:   cache,
+		logger:  log.NewNopLogger(),
+		started: time.Now(),
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.expiry, _ = cache.(arc.ExpiryService)
+	s.versions, _ = cache.(arc.VersionService)
+	s.counters, _ = cache.(arc.CounterService)
+	s.batch, _ = cache.(arc.BatchService)
+	s.pins, _ = cache.(arc.PinService)
+	s.tags, _ = cache.(arc.TagService)
+	s.scanner, _ = cache.(arc.ScanService)
+	s.loader, _ = cache.(arc.LoadService)
+	s.admission, _ = cache.(arc.AdmissionService)
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// ListenAndServe listens on the server address and serves connections until Close is called
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.connsMutex.Lock()
+	s.listener = l
+	s.connsMutex.Unlock()
+	s.logger.Info("RESP server listening", "addr", l.Addr().String())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.closed) == 1 {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.track(conn, true)
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes the open ones and waits for their handlers to return
+func (s *Server) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	s.connsMutex.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.connsMutex.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) track(c net.Conn, add bool) {
+	s.connsMutex.Lock()
+	defer s.connsMutex.Unlock()
+	if add {
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer s.wg.Done()
+	defer s.track(c, false)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := &writer{Writer: bufio.NewWriter(c), proto: 2}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if perr, ok := err.(protocolError); ok {
+				w.error("ERR Protocol error: " + string(perr))
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		atomic.AddUint64(&s.commands, 1)
+		quit := s.dispatch(args, w)
+		// replies of pipelined commands are written together
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
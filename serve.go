@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/memcache"
+	"github.com/deepak11627/arc/metrics"
+	"github.com/deepak11627/arc/resp"
+	"github.com/deepak11627/arc/server"
+)
+
+// Serve runs the HTTP server for cache a until SIGINT or SIGTERM is received.
+// The events published on the bus are streamed to the clients of /events.
+func Serve(a arc.CacheService, events *arc.EventBus, logger arc.Logger, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Address the HTTP server listens on.")
+	memcacheAddr := fs.String("memcache-addr", "", "Address the memcached protocol server listens on. Disabled when empty.")
+	respAddr := fs.String("resp-addr", "", "Address the Redis protocol server listens on. Disabled when empty.")
+	name := fs.String("name", "default", "Name of the cache in the cache label of the metrics.")
+	budget := fs.Int("namespace-budget", 0, "Number of entries shared by the namespaces served under /namespaces/. Disabled when 0.")
+	spaces := fs.String("namespaces", "", "Comma separated namespaces to create at startup as name:min or name:min:max, e.g. \"a:100:1000,b:50\".")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 10*time.Second, "Time allowed for requests in flight to finish on shutdown.")
+	fs.Parse(args)
+
+	if CacheSize <= 0 {
+		return errors.New("the size flag must be set to a positive number to serve the cache")
+	}
+
+	registry := metrics.NewRegistry()
+	registry.Register(*name, a)
+	opts := []server.Option{server.SetLogger(logger), server.SetRegistry(registry), server.SetEventBus(events)}
+	if *budget > 0 {
+		ns := arc.NewNamespaces(*budget, arc.SetLogger(logger))
+		if err := createNamespaces(ns, *spaces); err != nil {
+			return err
+		}
+		opts = append(opts, server.SetNamespaces(ns))
+	}
+	srv := server.NewServer(*addr, a, opts...)
+
+	errs := make(chan error, 3)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	var mc *memcache.Server
+	if *memcacheAddr != "" {
+		mc = memcache.NewServer(*memcacheAddr, a, memcache.SetLogger(logger))
+		go func() {
+			errs <- mc.ListenAndServe()
+		}()
+	}
+
+	var rs *resp.Server
+	if *respAddr != "" {
+		rs = resp.NewServer(*respAddr, a, resp.SetLogger(logger))
+		go func() {
+			errs <- rs.ListenAndServe()
+		}()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case s := <-sig:
+		logger.Info("Received signal, shutting down", "signal", s.String())
+	}
+
+	if mc != nil {
+		mc.Close()
+	}
+	if rs != nil {
+		rs.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// createNamespaces creates the namespaces of the namespaces flag
+func createNamespaces(ns *arc.Namespaces, flag string) error {
+	if flag == "" {
+		return nil
+	}
+	for _, s := range strings.Split(flag, ",") {
+		parts := strings.Split(s, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("namespace %q is not name:min or name:min:max", s)
+		}
+		var quota arc.Quota
+		var err error
+		if quota.Min, err = strconv.Atoi(parts[1]); err != nil {
+			return fmt.Errorf("namespace %q has an invalid min", s)
+		}
+		if len(parts) == 3 {
+			if quota.Max, err = strconv.Atoi(parts[2]); err != nil {
+				return fmt.Errorf("namespace %q has an invalid max", s)
+			}
+		}
+		if _, err := ns.Create(parts[0], quota); err != nil {
+			return fmt.Errorf("namespace %q: %v", s, err)
+		}
+	}
+	return nil
+}
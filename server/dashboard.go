@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// dashboardTop is the number of entries shown from the MRU end of each list
+const dashboardTop = 10
+
+// SetDashboardInterval function to set how often the dashboard is updated, every second by default
+func SetDashboardInterval(d time.Duration) func(*Server) {
+	return func(s *Server) {
+		s.dashboardInterval = d
+	}
+}
+
+// dashboardEvent is the state of the cache pushed to the dashboard
+type dashboardEvent struct {
+	Time   int64               `json:"time"`
+	C      int                 `json:"c"`
+	P      int                 `json:"p"`
+	T1     int                 `json:"t1"`
+	T2     int                 `json:"t2"`
+	B1     int                 `json:"b1"`
+	B2     int                 `json:"b2"`
+	Hits   uint64              `json:"hits"`
+	Misses uint64              `json:"misses"`
+	Top    map[string][]string `json:"top"`
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+// handleDashboardEvents streams the state of the cache as server-sent events
+func (s *Server) handleDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(s.dashboardInterval)
+	defer ticker.Stop()
+	for {
+		b, err := json.Marshal(s.dashboardEvent())
+		if err != nil {
+			s.logger.Error("Unable to encode dashboard event", "err", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Server) dashboardEvent() dashboardEvent {
+	stats := s.cache.Stats()
+	snap := s.cache.Snapshot()
+	return dashboardEvent{
+		Time:   time.Now().Unix(),
+		C:      stats.C,
+		P:      stats.P,
+		T1:     stats.T1,
+		T2:     stats.T2,
+		B1:     stats.B1,
+		B2:     stats.B2,
+		Hits:   stats.Hits,
+		Misses: stats.Misses,
+		Top: map[string][]string{
+			"t1": topKeys(snap.T1),
+			"t2": topKeys(snap.T2),
+			"b1": topKeys(snap.B1),
+			"b2": topKeys(snap.B2),
+		},
+	}
+}
+
+func topKeys(entries []arc.SnapshotEntry) []string {
+	if len(entries) > dashboardTop {
+		entries = entries[:dashboardTop]
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = fmt.Sprint(e.Key)
+	}
+	return keys
+}
+
+// dashboardHTML is self contained so the dashboard works without any external asset
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ARC dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+#bar { position: relative; display: flex; height: 32px; border: 1px solid #999; width: 100%; }
+#bar div { height: 100%; transition: width 0.3s; }
+#p { position: absolute; top: -6px; bottom: -6px; width: 2px; background: #000; }
+.t1 { background: #4e79a7; } .t2 { background: #59a14f; }
+.b1 { background: #a0cbe8; } .b2 { background: #8cd17d; }
+.legend span { display: inline-block; margin-right: 1.5em; }
+.legend i { display: inline-block; width: 12px; height: 12px; margin-right: 4px; vertical-align: middle; }
+canvas { border: 1px solid #ccc; }
+#lists { display: flex; gap: 2em; }
+#lists ol { margin: 0; padding-left: 1.5em; font-family: monospace; min-width: 10em; }
+#status { color: #999; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>ARC dashboard <span id="status">connecting...</span></h1>
+<div id="bar"><div class="t1" id="bt1"></div><div class="t2" id="bt2"></div><div class="b1" id="bb1"></div><div class="b2" id="bb2"></div><div id="p"></div></div>
+<p class="legend">
+<span><i class="t1"></i>T1 <b id="nt1">0</b></span>
+<span><i class="t2"></i>T2 <b id="nt2">0</b></span>
+<span><i class="b1"></i>B1 <b id="nb1">0</b></span>
+<span><i class="b2"></i>B2 <b id="nb2">0</b></span>
+<span>c <b id="nc">0</b></span>
+<span>p <b id="np">0</b></span>
+<span>hit ratio <b id="nhr">0</b></span>
+</p>
+<h2>p over time</h2>
+<canvas id="pchart" width="600" height="120"></canvas>
+<h2>Hit ratio</h2>
+<canvas id="hrchart" width="600" height="60"></canvas>
+<h2>Most recently used</h2>
+<div id="lists">
+<div><b>T1</b><ol id="lt1"></ol></div>
+<div><b>T2</b><ol id="lt2"></ol></div>
+<div><b>B1</b><ol id="lb1"></ol></div>
+<div><b>B2</b><ol id="lb2"></ol></div>
+</div>
+<script>
+var points = 120, ps = [], ratios = [], last = null;
+
+function line(id, values, max, color) {
+	var c = document.getElementById(id), ctx = c.getContext("2d");
+	ctx.clearRect(0, 0, c.width, c.height);
+	ctx.strokeStyle = color;
+	ctx.beginPath();
+	values.forEach(function (v, i) {
+		var x = i * c.width / (points - 1), y = c.height - (max > 0 ? v / max : 0) * (c.height - 4) - 2;
+		if (i === 0) { ctx.moveTo(x, y); } else { ctx.lineTo(x, y); }
+	});
+	ctx.stroke();
+}
+
+function fill(id, keys) {
+	var ol = document.getElementById(id);
+	ol.innerHTML = "";
+	(keys || []).forEach(function (k) {
+		var li = document.createElement("li");
+		li.textContent = k;
+		ol.appendChild(li);
+	});
+}
+
+var source = new EventSource("/dashboard/events");
+source.onopen = function () { document.getElementById("status").textContent = ""; };
+source.onerror = function () { document.getElementById("status").textContent = "disconnected"; };
+source.onmessage = function (msg) {
+	var e = JSON.parse(msg.data), total = Math.max(2 * e.c, 1);
+	["t1", "t2", "b1", "b2"].forEach(function (l) {
+		document.getElementById("b" + l).style.width = (100 * e[l] / total) + "%";
+		document.getElementById("n" + l).textContent = e[l];
+		fill("l" + l, e.top[l]);
+	});
+	document.getElementById("p").style.left = (100 * e.p / total) + "%";
+	document.getElementById("nc").textContent = e.c;
+	document.getElementById("np").textContent = e.p;
+
+	var ratio = 0;
+	if (last !== null) {
+		var hits = e.hits - last.hits, misses = e.misses - last.misses;
+		ratio = hits + misses > 0 ? hits / (hits + misses) : (ratios.length ? ratios[ratios.length - 1] : 0);
+	} else if (e.hits + e.misses > 0) {
+		ratio = e.hits / (e.hits + e.misses);
+	}
+	last = e;
+	document.getElementById("nhr").textContent = ratio.toFixed(2);
+
+	ps.push(e.p); ratios.push(ratio);
+	if (ps.length > points) { ps.shift(); ratios.shift(); }
+	line("pchart", ps, e.c, "#e15759");
+	line("hrchart", ratios, 1, "#4e79a7");
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// eventsBuffer is the number of events buffered for a client before it starts missing some
+const eventsBuffer = 1024
+
+// handleEvents streams the events of the cache as server-sent events, one JSON object each
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.events == nil {
+		writeError(w, http.StatusNotFound, "events are not enabled")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events, cancel := s.events.Subscribe(eventsBuffer)
+	defer cancel()
+	for {
+		select {
+		case e := <-events:
+			b, err := json.Marshal(e)
+			if err != nil {
+				s.logger.Error("Unable to encode event", "err", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b); err != nil {
+				return
+			}
+			// send whatever else is already queued before flushing
+			if len(events) == 0 {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
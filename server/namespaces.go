@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+const namespacesPrefix = "/namespaces/"
+
+// namespaces serves the caches of a namespace registry, each with the routes of a server
+type namespaces struct {
+	registry *arc.Namespaces
+	mutex    sync.Mutex
+	servers  map[string]*Server
+}
+
+// SetNamespaces function to serve the namespaces of a registry under /namespaces/, where
+// /namespaces/{name}/keys/{key} and the other routes act on the cache of the namespace.
+func SetNamespaces(n *arc.Namespaces) func(*Server) {
+	return func(s *Server) {
+		s.namespaces = &namespaces{registry: n, servers: make(map[string]*Server)}
+	}
+}
+
+// metricsName is the cache label of the metrics of a namespace
+func metricsName(name string) string {
+	return "namespace/" + name
+}
+
+// handleNamespaces lists the namespaces, and creates, describes and drops one or serves its cache
+func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	if s.namespaces == nil {
+		writeError(w, http.StatusNotFound, "namespaces are not enabled")
+		return
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/namespaces"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, namespacesResponse{
+			Budget:     s.namespaces.registry.Budget(),
+			Namespaces: s.namespaces.registry.List(),
+		})
+		return
+	}
+	name, rest := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		name, rest = path[:i], path[i:]
+	}
+	if rest != "" {
+		srv, ok := s.namespaceServer(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, "no such namespace")
+			return
+		}
+		http.StripPrefix(namespacesPrefix+name, srv.Handler()).ServeHTTP(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		for _, info := range s.namespaces.registry.List() {
+			if info.Name == name {
+				writeJSON(w, http.StatusOK, info)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "no such namespace")
+	case http.MethodPut:
+		s.createNamespace(w, r, name)
+	case http.MethodDelete:
+		if !s.namespaces.registry.Drop(name) {
+			writeError(w, http.StatusNotFound, "no such namespace")
+			return
+		}
+		s.registry.Unregister(metricsName(name))
+		s.namespaces.mutex.Lock()
+		delete(s.namespaces.servers, name)
+		s.namespaces.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// createNamespace creates the namespace name with the min and max query parameters as quota
+func (s *Server) createNamespace(w http.ResponseWriter, r *http.Request, name string) {
+	var quota arc.Quota
+	var err error
+	if quota.Min, err = strconv.Atoi(r.URL.Query().Get("min")); err != nil {
+		writeError(w, http.StatusBadRequest, "min is not an integer")
+		return
+	}
+	if max := r.URL.Query().Get("max"); max != "" {
+		if quota.Max, err = strconv.Atoi(max); err != nil {
+			writeError(w, http.StatusBadRequest, "max is not an integer")
+			return
+		}
+	}
+	cache, err := s.namespaces.registry.Create(name, quota)
+	switch err {
+	case nil:
+	case arc.ErrNamespaceExists, arc.ErrBudgetExceeded:
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.registry.Register(metricsName(name), cache)
+	writeJSON(w, http.StatusCreated, arc.NamespaceInfo{Name: name, Quota: quota, C: cache.Stats().C})
+}
+
+// namespaceServer returns the server of the cache of a namespace, created on first use
+func (s *Server) namespaceServer(name string) (*Server, bool) {
+	cache, ok := s.namespaces.registry.Get(name)
+	if !ok {
+		return nil, false
+	}
+	s.namespaces.mutex.Lock()
+	defer s.namespaces.mutex.Unlock()
+
+	srv, ok := s.namespaces.servers[name]
+	if !ok || srv.cache != arc.CacheService(cache) {
+		srv = NewServer("", cache, SetLogger(s.logger), SetRegistry(s.registry))
+		s.namespaces.servers[name] = srv
+	}
+	return srv, true
+}
+
+type namespacesResponse struct {
+	Budget     int                 `json:"budget"`
+	Namespaces []arc.NamespaceInfo `json:"namespaces"`
+}
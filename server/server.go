@@ -0,0 +1,554 @@
+// Package server exposes a cache over HTTP with JSON responses
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+	"github.com/deepak11627/arc/metrics"
+)
+
+const (
+	keysPrefix    = "/keys/"
+	inspectPrefix = "/inspect/"
+	pinsPrefix    = "/pins/"
+)
+
+// Server serves a cache over HTTP
+type Server struct {
+	cache    arc.CacheService
+	versions arc.VersionService
+	counters arc.CounterService
+	pins     arc.PinService
+	tags     arc.TagService
+	scanner  arc.ScanService
+	loader   arc.LoadService
+	logger   arc.Logger
+	registry *metrics.Registry
+	events   *arc.EventBus
+	// namespaces serves the namespaces of a registry set with SetNamespaces
+	namespaces *namespaces
+	http       *http.Server
+	ready      int32
+	// done is closed on shutdown to end the dashboard event streams
+	done chan struct{}
+
+	dashboardInterval time.Duration
+}
+
+// Option type setting params dynamically
+type Option func(*Server)
+
+// SetLogger function to set logger dynamically
+func SetLogger(l arc.Logger) func(*Server) {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// SetRegistry function to export the metrics of a registry shared with other caches on /metrics.
+// By default only the served cache is exported, under the name "default".
+func SetRegistry(r *metrics.Registry) func(*Server) {
+	return func(s *Server) {
+		s.registry = r
+	}
+}
+
+// SetEventBus function to stream the events published on a bus from /events.
+// The bus must be subscribed to the cache with arc.SetObserver.
+func SetEventBus(b *arc.EventBus) func(*Server) {
+	return func(s *Server) {
+		s.events = b
+	}
+}
+
+// NewServer returns a server listening on addr once started
+func NewServer(addr string, cache arc.CacheService, opts ...Option) *Server {
+	s := &Server{
+		cache:             cache,
+		logger:            log.NewNopLogger(),
+		done:              make(chan struct{}),
+		dashboardInterval: time.Second,
+	}
+	s.versions, _ = cache.(arc.VersionService)
+	s.counters, _ = cache.(arc.CounterService)
+	s.pins, _ = cache.(arc.PinService)
+	s.tags, _ = cache.(arc.TagService)
+	s.scanner, _ = cache.(arc.ScanService)
+	s.loader, _ = cache.(arc.LoadService)
+	for _, o := range opts {
+		o(s)
+	}
+	if s.registry == nil {
+		s.registry = metrics.NewRegistry()
+		s.registry.Register("default", cache)
+	}
+	if s.namespaces != nil {
+		for _, info := range s.namespaces.registry.List() {
+			if cache, ok := s.namespaces.registry.Get(info.Name); ok {
+				s.registry.Register(metricsName(info.Name), cache)
+			}
+		}
+	}
+	s.http = &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+	return s
+}
+
+// Handler returns the routes of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", s.handleKeys)
+	mux.HandleFunc(keysPrefix, s.handleKey)
+	mux.HandleFunc(inspectPrefix, s.handleInspect)
+	mux.HandleFunc(pinsPrefix, s.handlePin)
+	mux.HandleFunc("/invalidate", s.handleInvalidate)
+	mux.HandleFunc("/namespaces", s.handleNamespaces)
+	mux.HandleFunc(namespacesPrefix, s.handleNamespaces)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/snapshot", s.handleSnapshot)
+	mux.Handle("/metrics", s.registry)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
+	mux.HandleFunc("/dashboard/events", s.handleDashboardEvents)
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	return mux
+}
+
+// ListenAndServe starts accepting requests, it blocks until the server is shut down
+func (s *Server) ListenAndServe() error {
+	atomic.StoreInt32(&s.ready, 1)
+	s.logger.Info("HTTP server listening", "addr", s.http.Addr)
+	err := s.http.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests, waits for the ones in flight and flushes
+// the cache's pending database writes.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if atomic.SwapInt32(&s.ready, 0) == 1 {
+		close(s.done)
+	}
+	s.logger.Info("HTTP server shutting down")
+	if err := s.http.Shutdown(ctx); err != nil {
+		return err
+	}
+	if f, ok := s.cache.(arc.FlushService); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, keysPrefix)
+	if key == "" {
+		s.handleKeys(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getKey(w, key)
+	case http.MethodPut:
+		s.putKey(w, r, key)
+	case http.MethodPost:
+		s.incrKey(w, r, key)
+	case http.MethodDelete:
+		if !s.cache.Delete(key) {
+			writeError(w, http.StatusNotFound, "no such key")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleKeys lists a page of keys from the cursor query parameter, 0 or none for the first page,
+// matching the match glob and about count long
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.scanner == nil {
+		writeError(w, http.StatusNotImplemented, "the cache does not support scanning")
+		return
+	}
+	q := r.URL.Query()
+	var cursor uint64
+	if c := q.Get("cursor"); c != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(c, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+	var count int
+	if c := q.Get("count"); c != "" {
+		var err error
+		if count, err = strconv.Atoi(c); err != nil || count <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid count")
+			return
+		}
+	}
+	found, next := s.scanner.Scan(cursor, q.Get("match"), count)
+	page := keysPage{Keys: make([]string, len(found)), Cursor: strconv.FormatUint(next, 10)}
+	for i, key := range found {
+		page.Keys[i] = fmt.Sprint(key)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) getKey(w http.ResponseWriter, key string) {
+	if s.loader != nil {
+		if v, err := s.loader.GetOrLoad(key); err != arc.ErrNoLoader {
+			s.loadedKey(w, key, v, err)
+			return
+		}
+	}
+	var v interface{}
+	var ok bool
+	if s.versions != nil {
+		var version uint64
+		if v, version, ok = s.versions.GetWithVersion(key); ok {
+			w.Header().Set("ETag", etag(version))
+		}
+	} else {
+		v, ok = s.cache.Get(key)
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no such key")
+		return
+	}
+	writeJSON(w, http.StatusOK, keyValue{Key: key, Value: v})
+}
+
+// loadedKey writes the value GetOrLoad returned, 502 when the loader failed
+func (s *Server) loadedKey(w http.ResponseWriter, key string, v interface{}, err error) {
+	switch {
+	case err == arc.ErrNotFound:
+		writeError(w, http.StatusNotFound, "no such key")
+		return
+	case err != nil:
+		s.logger.Error("unable to load key", "key", key, "err", err)
+		writeError(w, http.StatusBadGateway, "unable to load key")
+		return
+	}
+	if s.versions != nil {
+		if version, ok := s.versions.Version(key); ok {
+			w.Header().Set("ETag", etag(version))
+		}
+	}
+	writeJSON(w, http.StatusOK, keyValue{Key: key, Value: v})
+}
+
+// putKey stores the body at key, with the tag query parameters as tags. With versions,
+// If-Match only replaces the value of the given ETag and If-None-Match: * only stores keys
+// which are not cached.
+func (s *Server) putKey(w http.ResponseWriter, r *http.Request, key string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unable to read value")
+		return
+	}
+	value := string(body)
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	tags := r.URL.Query()["tag"]
+	if len(tags) > 0 {
+		if s.tags == nil {
+			writeError(w, http.StatusNotImplemented, "the cache does not support tags")
+			return
+		}
+		if ifMatch != "" || ifNoneMatch != "" {
+			writeError(w, http.StatusBadRequest, "tags can not be set by conditional writes")
+			return
+		}
+	}
+	if s.versions == nil {
+		if ifMatch != "" || ifNoneMatch != "" {
+			writeError(w, http.StatusNotImplemented, "conditional writes need a cache with versions")
+			return
+		}
+		status := http.StatusCreated
+		if s.put(key, value, tags) {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, keyValue{Key: key, Value: value})
+		return
+	}
+
+	status := http.StatusOK
+	var version uint64
+	switch {
+	case ifMatch != "":
+		expected, err := strconv.ParseUint(strings.Trim(ifMatch, `"`), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusPreconditionFailed, "version mismatch")
+			return
+		}
+		version, err = s.versions.CompareAndSwap(key, expected, value)
+		switch {
+		case err == arc.ErrNotFound:
+			writeError(w, http.StatusNotFound, "no such key")
+			return
+		case err == arc.ErrVersionMismatch:
+			writeError(w, http.StatusPreconditionFailed, "version mismatch")
+			return
+		case err != nil:
+			s.logger.Error("unable to store key", "key", key, "err", err)
+			writeError(w, http.StatusInternalServerError, "unable to store key")
+			return
+		}
+	case ifNoneMatch == "*":
+		if !s.versions.PutIfAbsent(key, value) {
+			writeError(w, http.StatusPreconditionFailed, "key already exists")
+			return
+		}
+		status = http.StatusCreated
+	default:
+		if !s.put(key, value, tags) {
+			status = http.StatusCreated
+		}
+	}
+	if version == 0 {
+		version, _ = s.versions.Version(key)
+	}
+	if version != 0 {
+		w.Header().Set("ETag", etag(version))
+	}
+	writeJSON(w, status, keyValue{Key: key, Value: value})
+}
+
+// put stores value at key, with tags when there are some
+func (s *Server) put(key, value string, tags []string) bool {
+	if len(tags) > 0 {
+		return s.tags.PutWithTags(key, value, 0, tags...)
+	}
+	return s.cache.Put(key, value)
+}
+
+// handleInvalidate removes the keys with the tag query parameter, or starting with prefix
+func (s *Server) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.tags == nil {
+		writeError(w, http.StatusNotImplemented, "the cache does not support tags")
+		return
+	}
+	q := r.URL.Query()
+	var n int
+	switch {
+	case q.Get("tag") != "":
+		n = s.tags.InvalidateTag(q.Get("tag"))
+	case q.Get("prefix") != "":
+		n = s.tags.InvalidatePrefix(q.Get("prefix"))
+	default:
+		writeError(w, http.StatusBadRequest, "missing tag or prefix")
+		return
+	}
+	writeJSON(w, http.StatusOK, invalidated{Invalidated: n})
+}
+
+// incrKey adds the by query parameter to the number at key, 1 by default. Negative values
+// decrement it and values which are not integers make it a float.
+func (s *Server) incrKey(w http.ResponseWriter, r *http.Request, key string) {
+	if s.counters == nil {
+		writeError(w, http.StatusNotImplemented, "the cache does not support counters")
+		return
+	}
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "1"
+	}
+	var value interface{}
+	var err error
+	if d, perr := strconv.ParseInt(by, 10, 64); perr == nil {
+		value, err = s.counters.Increment(key, d)
+	} else if f, perr := strconv.ParseFloat(by, 64); perr == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		value, err = s.counters.IncrementFloat(key, f)
+	} else {
+		writeError(w, http.StatusBadRequest, "by is not a number")
+		return
+	}
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, keyValue{Key: key, Value: value})
+	case arc.ErrOverflow:
+		writeError(w, http.StatusConflict, "increment would overflow")
+	case arc.ErrNotNumber:
+		writeError(w, http.StatusConflict, "value is not a number")
+	default:
+		s.logger.Error("unable to store key", "key", key, "err", err)
+		writeError(w, http.StatusInternalServerError, "unable to store key")
+	}
+}
+
+// etag formats a version as a strong entity tag
+func etag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.cache.Stats()
+	resp := statsResponse{
+		Len:      s.cache.Len(),
+		C:        stats.C,
+		P:        stats.P,
+		T1:       stats.T1,
+		T2:       stats.T2,
+		B1:       stats.B1,
+		B2:       stats.B2,
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		HitRatio: stats.HitRatio(),
+		Pinned:   stats.Pinned,
+		Rejected: stats.Rejected,
+	}
+	for _, sh := range stats.Shadows {
+		resp.Shadows = append(resp.Shadows, shadowResponse{
+			Name:     sh.Name,
+			Capacity: sh.Capacity,
+			Hits:     sh.Hits,
+			Misses:   sh.Misses,
+			HitRatio: sh.HitRatio(),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleInspect explains where a key is and how it got there
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	inspector, ok := s.cache.(arc.InspectService)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "the cache cannot be inspected")
+		return
+	}
+	info, ok := inspector.Inspect(strings.TrimPrefix(r.URL.Path, inspectPrefix))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown key")
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handlePin pins a key on PUT and unpins it on DELETE
+func (s *Server) handlePin(w http.ResponseWriter, r *http.Request) {
+	if s.pins == nil {
+		writeError(w, http.StatusNotImplemented, "the cache does not support pins")
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, pinsPrefix)
+	switch r.Method {
+	case http.MethodPut:
+		switch s.pins.Pin(key) {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case arc.ErrPinLimit:
+			writeError(w, http.StatusConflict, "pinned capacity reached")
+		default:
+			writeError(w, http.StatusNotFound, "no such key")
+		}
+	case http.MethodDelete:
+		if !s.pins.Unpin(key) {
+			writeError(w, http.StatusNotFound, "key is not pinned")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cache.Snapshot())
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, status{Status: "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, status{Status: "shutting down"})
+		return
+	}
+	writeJSON(w, http.StatusOK, status{Status: "ready"})
+}
+
+type keyValue struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type statsResponse struct {
+	Len      int              `json:"len"`
+	C        int              `json:"c"`
+	P        int              `json:"p"`
+	T1       int              `json:"t1"`
+	T2       int              `json:"t2"`
+	B1       int              `json:"b1"`
+	B2       int              `json:"b2"`
+	Hits     uint64           `json:"hits"`
+	Misses   uint64           `json:"misses"`
+	HitRatio float64          `json:"hit_ratio"`
+	Pinned   int              `json:"pinned"`
+	Rejected uint64           `json:"rejected"`
+	Shadows  []shadowResponse `json:"shadows,omitempty"`
+}
+
+type shadowResponse struct {
+	Name     string  `json:"name"`
+	Capacity int     `json:"capacity"`
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// keysPage is a page of keys, the cursor is a string since JSON numbers cannot hold every uint64
+type keysPage struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor"`
+}
+
+type invalidated struct {
+	Invalidated int `json:"invalidated"`
+}
+
+type status struct {
+	Status string `json:"status"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{msg})
+}
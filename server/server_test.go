@@ -0,0 +1,119 @@
+package server
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+var errUnavailable = errors.New("store unavailable")
+
+// flakyStore holds nothing and fails every save while failing is set
+type flakyStore struct {
+	failing int32
+}
+
+func (s *flakyStore) Load(key interface{}) (interface{}, error) {
+	return nil, arc.ErrNotFound
+}
+
+func (s *flakyStore) Save(key, value interface{}) error {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		return errUnavailable
+	}
+	return nil
+}
+
+func (s *flakyStore) Delete(key interface{}) error {
+	return nil
+}
+
+func (s *flakyStore) fail(failing bool) {
+	var v int32
+	if failing {
+		v = 1
+	}
+	atomic.StoreInt32(&s.failing, v)
+}
+
+func newCache(c int, opts ...arc.Option) arc.CacheService {
+	opts = append([]arc.Option{arc.SetLogger(log.NewNopLogger())}, opts...)
+	return arc.NewARC(c, list.New(), list.New(), list.New(), list.New(), opts...)
+}
+
+// do sends a request to the routes of s and returns the recorded response
+func do(s *Server, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	return w
+}
+
+func TestPutIfMatch(t *testing.T) {
+	store := &flakyStore{}
+	s := NewServer("", newCache(10, arc.SetStore(store, arc.WriteThrough)))
+	w := do(s, http.MethodPut, "/keys/k", "a", nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("put: got %d, want %d", w.Code, http.StatusCreated)
+	}
+	version := w.Header().Get("ETag")
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		failing bool
+		want    int
+	}{
+		{"stale version", `"1"`, false, http.StatusPreconditionFailed},
+		{"store error", version, true, http.StatusInternalServerError},
+		{"current version", version, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.fail(tt.failing)
+			w := do(s, http.MethodPut, "/keys/k", "b", map[string]string{"If-Match": tt.ifMatch})
+			if w.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+	if w := do(s, http.MethodPut, "/keys/missing", "b", map[string]string{"If-Match": version}); w.Code != http.StatusNotFound {
+		t.Fatalf("missing key: got %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestIncr(t *testing.T) {
+	store := &flakyStore{}
+	s := NewServer("", newCache(10, arc.SetStore(store, arc.WriteThrough)))
+	do(s, http.MethodPut, "/keys/s", "a", nil)
+
+	tests := []struct {
+		name    string
+		target  string
+		failing bool
+		want    int
+	}{
+		{"counter", "/keys/n?by=2", false, http.StatusOK},
+		{"not a number", "/keys/s", false, http.StatusConflict},
+		{"store error", "/keys/n", true, http.StatusInternalServerError},
+		{"float store error", "/keys/n?by=0.5", true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.fail(tt.failing)
+			if w := do(s, http.MethodPost, tt.target, "", nil); w.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
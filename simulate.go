@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/deepak11627/arc/trace"
+	"github.com/deepak11627/arc/utils"
+)
+
+// Simulate replays the trace files, oldest first, into the shadow caches of the shadows flag
+// and prints the hit ratio each of them would have had
+func Simulate(files []string) error {
+	if len(files) == 0 {
+		return errors.New("usage: simulate trace-file [trace-file...], oldest first")
+	}
+	shadowCaches, err := ParseShadows(shadows)
+	if err != nil {
+		return err
+	}
+	if len(shadowCaches) == 0 {
+		return errors.New("the shadows flag must list the caches to simulate, e.g. \"arc:200,lru:100\"")
+	}
+
+	readers := make([]io.Reader, len(files))
+	for i, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers[i] = f
+	}
+	stats, err := trace.Replay(trace.NewReader(io.MultiReader(readers...)), shadowCaches...)
+	if err != nil {
+		return err
+	}
+
+	utils.RenderMessageHeading("Simulation results.")
+	for _, s := range stats {
+		fmt.Printf("\n%s: hits %d, misses %d, hit ratio %.2f", s.Name, s.Hits, s.Misses, s.HitRatio())
+	}
+	fmt.Println()
+	utils.RenderMessageEnd()
+	return nil
+}
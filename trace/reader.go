@@ -0,0 +1,73 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/deepak11627/arc/arc"
+)
+
+// Reader reads the records of a trace one at a time
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewReader returns a reader for the trace in r
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &Reader{s: s}
+}
+
+// Next returns the next record, or io.EOF once the trace is exhausted
+func (r *Reader) Next() (Record, error) {
+	var rec Record
+	for r.s.Scan() {
+		r.line++
+		b := r.s.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return rec, fmt.Errorf("trace: line %d: %v", r.line, err)
+		}
+		return rec, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return rec, err
+	}
+	return rec, io.EOF
+}
+
+// Replay feeds the records of r into the shadow simulations and returns their statistics.
+// Gets are counted as hits or misses, Puts only update the simulations.
+func Replay(r *Reader, shadows ...arc.Shadow) ([]arc.ShadowStats, error) {
+	stats := make([]arc.ShadowStats, len(shadows))
+	for i, s := range shadows {
+		stats[i] = arc.ShadowStats{Name: s.Name(), Capacity: s.Capacity()}
+	}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+		for i, s := range shadows {
+			switch rec.Op {
+			case arc.TraceGet:
+				if s.Get(rec.Key) {
+					stats[i].Hits++
+				} else {
+					stats[i].Misses++
+				}
+			case arc.TracePut:
+				s.Put(rec.Key)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package trace records the key stream of a live cache to JSON lines files
+// and replays them into shadow simulations offline.
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/deepak11627/arc/arc"
+	"github.com/deepak11627/arc/log"
+)
+
+// bufferSize is the number of records a Recorder holds while its writer catches up
+const bufferSize = 8192
+
+// Record is a single operation of a trace, written as a line like
+// {"t":1700000000000000000,"op":"get","k":"user:1"}
+type Record struct {
+	// Time is the Unix time of the operation in nanoseconds
+	Time int64  `json:"t"`
+	Op   string `json:"op"`
+	Key  string `json:"k"`
+}
+
+// Recorder is an arc.TraceService appending records to a file, rotated by size. Records are
+// written from a goroutine of its own so that the cache never waits for the file, those
+// arriving while its buffer is full are dropped.
+type Recorder struct {
+	// dropped comes first to be 64-bit aligned for atomic access
+	dropped    uint64
+	path       string
+	logger     arc.Logger
+	hashKeys   bool
+	sampleRate float64
+	maxSize    int64
+	maxFiles   int
+
+	// mutex guards closed, the file is only used by the writer goroutine
+	mutex   sync.RWMutex
+	closed  bool
+	records chan request
+	done    chan struct{}
+
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+// request is a record to write, or a flush when flushed is set
+type request struct {
+	rec     Record
+	flushed chan error
+}
+
+// Option type setting params dynamically
+type Option func(*Recorder)
+
+// SetLogger function to set logger dynamically
+func SetLogger(l arc.Logger) func(*Recorder) {
+	return func(r *Recorder) {
+		r.logger = l
+	}
+}
+
+// SetHashKeys replaces every key by its 64-bit FNV-1a hash, so traces can be shared
+// without revealing the keys while still telling them apart
+func SetHashKeys(hash bool) func(*Recorder) {
+	return func(r *Recorder) {
+		r.hashKeys = hash
+	}
+}
+
+// SetSampleRate records only the given fraction of the keys, 1 by default.
+// Sampling picks keys rather than operations, so a sampled key keeps its whole history
+// and the trace stays meaningful for a cache scaled down by the same fraction.
+func SetSampleRate(rate float64) func(*Recorder) {
+	return func(r *Recorder) {
+		r.sampleRate = rate
+	}
+}
+
+// SetRotation rotates the file once it grows past maxSize bytes, keeping maxFiles old files
+// named path.1 (the most recent) to path.N. A maxSize of zero disables rotation.
+func SetRotation(maxSize int64, maxFiles int) func(*Recorder) {
+	return func(r *Recorder) {
+		r.maxSize = maxSize
+		r.maxFiles = maxFiles
+	}
+}
+
+// NewRecorder returns a recorder appending to the file at path
+func NewRecorder(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:       path,
+		logger:     log.NewNopLogger(),
+		sampleRate: 1,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.records = make(chan request, bufferSize)
+	r.done = make(chan struct{})
+	go r.run()
+	return r, nil
+}
+
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.w = bufio.NewWriter(f)
+	r.size = info.Size()
+	return nil
+}
+
+// Record appends an operation on key to the trace, unless the key is sampled out
+func (r *Recorder) Record(op string, key interface{}) {
+	k := fmt.Sprint(key)
+	h := hash(k)
+	if r.sampleRate < 1 && float64(h%10000) >= r.sampleRate*10000 {
+		return
+	}
+	if r.hashKeys {
+		k = strconv.FormatUint(h, 16)
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.closed {
+		return
+	}
+	select {
+	case r.records <- request{rec: Record{Time: time.Now().UnixNano(), Op: op, Key: k}}:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+// Dropped returns the number of records missed because the buffer was full
+func (r *Recorder) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// run writes the records until Close
+func (r *Recorder) run() {
+	defer close(r.done)
+	for req := range r.records {
+		if req.flushed != nil {
+			var err error
+			if r.w != nil {
+				err = r.w.Flush()
+			}
+			req.flushed <- err
+			continue
+		}
+		r.write(req.rec)
+	}
+}
+
+func (r *Recorder) write(rec Record) {
+	if r.w == nil {
+		return
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		r.logger.Error("Unable to encode trace record", "err", err)
+		return
+	}
+	b = append(b, '\n')
+	n, err := r.w.Write(b)
+	r.size += int64(n)
+	if err != nil {
+		r.logger.Error("Unable to write trace record", "path", r.path, "err", err)
+		return
+	}
+	if r.maxSize > 0 && r.size >= r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.logger.Error("Unable to rotate trace file", "path", r.path, "err", err)
+		}
+	}
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1 and starts a new file
+func (r *Recorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	if r.maxFiles > 0 {
+		os.Remove(r.path + "." + strconv.Itoa(r.maxFiles))
+		for i := r.maxFiles - 1; i > 0; i-- {
+			os.Rename(r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Flush waits for the records recorded so far to be written to the file
+func (r *Recorder) Flush() error {
+	r.mutex.RLock()
+	if r.closed {
+		r.mutex.RUnlock()
+		return nil
+	}
+	flushed := make(chan error, 1)
+	r.records <- request{flushed: flushed}
+	r.mutex.RUnlock()
+
+	return <-flushed
+}
+
+// Close writes the buffered records and closes the file, later records are ignored
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.records)
+	}
+	r.mutex.Unlock()
+
+	<-r.done
+	if n := r.Dropped(); n > 0 {
+		r.logger.Warn("Trace records dropped, the file could not keep up", "path", r.path, "count", n)
+	}
+	return r.closeFile()
+}
+
+func (r *Recorder) closeFile() error {
+	if r.w == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.f, r.w = nil, nil
+	return err
+}
+
+func hash(k string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(k))
+	return h.Sum64()
+}